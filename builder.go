@@ -0,0 +1,178 @@
+package flaps
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ConfigBuilder assembles a MachineConfig step by step. Invalid values are
+// recorded rather than reported right away; Build returns them all.
+type ConfigBuilder struct {
+	config MachineConfig
+	errs   []error
+}
+
+func NewConfigBuilder() *ConfigBuilder {
+	return &ConfigBuilder{}
+}
+
+func (b *ConfigBuilder) Image(ref string) *ConfigBuilder {
+	if ref == "" {
+		b.errs = append(b.errs, errors.New("image must not be empty"))
+	}
+	b.config.Image = ref
+	return b
+}
+
+// publicRegistries are the registries FromImage doesn't require credentials
+// for.
+var publicRegistries = map[string]bool{
+	"docker.io":            true,
+	"index.docker.io":      true,
+	"registry-1.docker.io": true,
+	"registry.fly.io":      true,
+	"ghcr.io":              true,
+	"quay.io":              true,
+	"public.ecr.aws":       true,
+}
+
+// FromImage sets the image and the credentials to pull it with. Images from
+// registries other than Docker Hub, the Fly registry and a few well-known
+// public ones are assumed to be private and need auth.
+func (b *ConfigBuilder) FromImage(ref string, auth *RegistryAuth) *ConfigBuilder {
+	b.Image(ref)
+	if ref == "" {
+		return b
+	}
+
+	parsed, err := ParseImageRef(ref)
+	switch {
+	case err != nil:
+		b.errs = append(b.errs, err)
+	case auth == nil && !publicRegistries[parsed.Registry]:
+		b.errs = append(b.errs, fmt.Errorf("image %s is from private registry %s, but no registry auth is given", ref, parsed.Registry))
+	case auth != nil && (auth.Username == "" || auth.Password == ""):
+		b.errs = append(b.errs, errors.New("registry auth needs a username and a password"))
+	}
+
+	if auth != nil {
+		copied := *auth
+		b.config.RegistryAuth = &copied
+	} else {
+		b.config.RegistryAuth = nil
+	}
+	return b
+}
+
+// Guest sizes the machine with shared CPUs. Use GuestPreset for dedicated
+// ones.
+func (b *ConfigBuilder) Guest(cpus, memoryMB int) *ConfigBuilder {
+	if cpus <= 0 {
+		b.errs = append(b.errs, fmt.Errorf("guest cpus must be positive, got %d", cpus))
+	}
+	if memoryMB <= 0 {
+		b.errs = append(b.errs, fmt.Errorf("guest memory must be positive, got %d MB", memoryMB))
+	}
+	b.config.Guest = &MachineGuest{CPUKind: "shared", CPUs: cpus, MemoryMB: memoryMB}
+	return b
+}
+
+// GuestPreset sizes the machine after one of MachinePresets, e.g.
+// "shared-cpu-1x".
+func (b *ConfigBuilder) GuestPreset(name string) *ConfigBuilder {
+	preset, ok := MachinePresets[name]
+	if !ok {
+		b.errs = append(b.errs, fmt.Errorf("unknown guest preset %q", name))
+		return b
+	}
+	guest := *preset
+	b.config.Guest = &guest
+	return b
+}
+
+func (b *ConfigBuilder) AddService(service MachineService) *ConfigBuilder {
+	if service.InternalPort <= 0 || service.InternalPort > 65535 {
+		b.errs = append(b.errs, fmt.Errorf("service internal port must be between 1 and 65535, got %d", service.InternalPort))
+	}
+	b.config.Services = append(b.config.Services, service)
+	return b
+}
+
+func (b *ConfigBuilder) AddMount(volumeID, path string) *ConfigBuilder {
+	if volumeID == "" {
+		b.errs = append(b.errs, errors.New("mount volume must not be empty"))
+	}
+	if path == "" {
+		b.errs = append(b.errs, errors.New("mount path must not be empty"))
+	}
+	b.config.Mounts = append(b.config.Mounts, MachineMount{Volume: volumeID, Path: path})
+	return b
+}
+
+// Env merges env into the machine's environment.
+func (b *ConfigBuilder) Env(env map[string]string) *ConfigBuilder {
+	if b.config.Env == nil {
+		b.config.Env = make(map[string]string, len(env))
+	}
+	for k, v := range env {
+		b.config.Env[k] = v
+	}
+	return b
+}
+
+func (b *ConfigBuilder) Metadata(key, value string) *ConfigBuilder {
+	if key == "" {
+		b.errs = append(b.errs, errors.New("metadata key must not be empty"))
+	}
+	if b.config.Metadata == nil {
+		b.config.Metadata = make(map[string]string)
+	}
+	b.config.Metadata[key] = value
+	return b
+}
+
+func (b *ConfigBuilder) Restart(policy MachineRestartPolicy, maxRetries int) *ConfigBuilder {
+	b.config.Restart = MachineRestart{Policy: policy, MaxRetries: maxRetries}
+	return b
+}
+
+// Exec sets the command init runs in place of the image's entrypoint and
+// cmd. It can't be combined with Entrypoint or Cmd.
+func (b *ConfigBuilder) Exec(command []string) *ConfigBuilder {
+	b.config.Init.Exec = append([]string(nil), command...)
+	return b
+}
+
+// Entrypoint overrides the image's entrypoint.
+func (b *ConfigBuilder) Entrypoint(entrypoint []string) *ConfigBuilder {
+	b.config.Init.Entrypoint = append([]string(nil), entrypoint...)
+	return b
+}
+
+// Cmd overrides the image's cmd, the arguments passed to its entrypoint.
+func (b *ConfigBuilder) Cmd(cmd []string) *ConfigBuilder {
+	b.config.Init.Cmd = append([]string(nil), cmd...)
+	return b
+}
+
+// TTY sets whether the machine's process is given a terminal.
+func (b *ConfigBuilder) TTY(tty bool) *ConfigBuilder {
+	b.config.Init.Tty = tty
+	return b
+}
+
+// Build returns the assembled config, or every problem found while building
+// it.
+func (b *ConfigBuilder) Build() (*MachineConfig, error) {
+	if err := errors.Join(b.errs...); err != nil {
+		return nil, err
+	}
+	config, err := copyConfig(&b.config)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,195 @@
+package flaps
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigBuilder(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func(*ConfigBuilder)
+		wantErr []string
+	}{
+		{"minimal", func(b *ConfigBuilder) { b.Image("nginx") }, nil},
+		{"no image", func(b *ConfigBuilder) { b.Guest(1, 256) }, []string{"config.image is required"}},
+		{"empty image", func(b *ConfigBuilder) { b.Image("") }, []string{"image must not be empty"}},
+		{
+			"every problem",
+			func(b *ConfigBuilder) {
+				b.Image("nginx").
+					Guest(0, -1).
+					AddService(MachineService{InternalPort: 70000}).
+					AddMount("", "").
+					Metadata("", "x")
+			},
+			[]string{
+				"guest cpus must be positive, got 0",
+				"guest memory must be positive, got -1 MB",
+				"service internal port must be between 1 and 65535, got 70000",
+				"mount volume must not be empty",
+				"mount path must not be empty",
+				"metadata key must not be empty",
+			},
+		},
+		{"unknown preset", func(b *ConfigBuilder) { b.Image("nginx").GuestPreset("huge") }, []string{`unknown guest preset "huge"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewConfigBuilder()
+			tt.build(b)
+			config, err := b.Build()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got config %+v, want an error", config)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigBuilderBuild(t *testing.T) {
+	b := NewConfigBuilder().
+		Image("nginx").
+		GuestPreset("shared-cpu-1x").
+		AddService(MachineService{Protocol: "tcp", InternalPort: 8080}).
+		AddMount("vol_1", "/data").
+		Env(map[string]string{"PORT": "8080"}).
+		Env(map[string]string{"MODE": "web"}).
+		Metadata(MetadataProcessGroup, "web").
+		Restart(MachineRestartPolicyAlways, 0)
+
+	config, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &MachineConfig{
+		Image:    "nginx",
+		Guest:    &MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: MEMORY_MB_PER_SHARED_CPU},
+		Services: []MachineService{{Protocol: "tcp", InternalPort: 8080}},
+		Mounts:   []MachineMount{{Volume: "vol_1", Path: "/data"}},
+		Env:      map[string]string{"PORT": "8080", "MODE": "web"},
+		Metadata: map[string]string{MetadataProcessGroup: "web"},
+		Restart:  MachineRestart{Policy: MachineRestartPolicyAlways},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("got config %+v, want %+v", config, want)
+	}
+
+	// The built config is a copy, unaffected by later changes.
+	b.Env(map[string]string{"PORT": "9090"})
+	if config.Env["PORT"] != "8080" {
+		t.Fatalf("changing the builder changed the built config's env to %v", config.Env)
+	}
+}
+
+func TestConfigBuilderFromImage(t *testing.T) {
+	auth := &RegistryAuth{Username: "user", Password: "secret"}
+
+	tests := []struct {
+		name    string
+		ref     string
+		auth    *RegistryAuth
+		wantErr string
+	}{
+		{"docker hub", "nginx:1.25", nil, ""},
+		{"fly registry", "registry.fly.io/my-app:deployment-01", nil, ""},
+		{"public registry", "ghcr.io/org/app:v1", nil, ""},
+		{"private registry without auth", "registry.example.com:5000/app", nil, "private registry registry.example.com:5000"},
+		{"private registry with auth", "registry.example.com:5000/app", auth, ""},
+		{"incomplete auth", "registry.example.com/app", &RegistryAuth{Username: "user"}, "needs a username and a password"},
+		{"malformed reference", "nginx:", nil, "empty tag"},
+		{"empty reference", "", nil, "image must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewConfigBuilder().FromImage(tt.ref, tt.auth).Build()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Image != tt.ref {
+				t.Errorf("got image %q, want %q", config.Image, tt.ref)
+			}
+			if !reflect.DeepEqual(config.RegistryAuth, tt.auth) {
+				t.Errorf("got registry auth %+v, want %+v", config.RegistryAuth, tt.auth)
+			}
+		})
+	}
+}
+
+func TestConfigBuilderFromImageCopiesAuth(t *testing.T) {
+	auth := &RegistryAuth{Username: "user", Password: "secret"}
+	b := NewConfigBuilder().FromImage("registry.example.com/app", auth)
+	auth.Password = "changed"
+
+	config, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RegistryAuth.Password != "secret" {
+		t.Fatalf("got password %q, want the one given to FromImage", config.RegistryAuth.Password)
+	}
+
+	// A later public image drops the credentials of the earlier one.
+	config, err = b.FromImage("nginx", nil).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RegistryAuth != nil {
+		t.Fatalf("got registry auth %+v for a public image, want none", config.RegistryAuth)
+	}
+}
+
+func TestConfigBuilderInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func(*ConfigBuilder)
+		want    MachineInit
+		wantErr bool
+	}{
+		{"exec", func(b *ConfigBuilder) { b.Exec([]string{"/bin/sleep", "inf"}) }, MachineInit{Exec: []string{"/bin/sleep", "inf"}}, false},
+		{
+			"entrypoint and cmd",
+			func(b *ConfigBuilder) { b.Entrypoint([]string{"bundle"}).Cmd([]string{"exec", "puma"}).TTY(true) },
+			MachineInit{Entrypoint: []string{"bundle"}, Cmd: []string{"exec", "puma"}, Tty: true},
+			false,
+		},
+		{"exec and entrypoint", func(b *ConfigBuilder) { b.Exec([]string{"run"}).Entrypoint([]string{"sh"}) }, MachineInit{}, true},
+		{"exec and cmd", func(b *ConfigBuilder) { b.Cmd([]string{"serve"}).Exec([]string{"run"}) }, MachineInit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewConfigBuilder().Image("nginx")
+			tt.build(b)
+			config, err := b.Build()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "config.init.exec can't be combined") {
+					t.Fatalf("got error %v, want the exec conflict", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config.Init, tt.want) {
+				t.Fatalf("got init %+v, want %+v", config.Init, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package flaps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StopMachines stops the given machines, running at most concurrency stops
+// at once. It returns each machine's outcome: nil once stopped, or the error
+// that prevented it. Once ctx is done no further stops are started, and the
+// machines left over are reported with ctx's error.
+func (f *Client) StopMachines(ctx context.Context, ids []string, concurrency int) map[string]error {
+	return forEachMachine(ctx, ids, concurrency, func(ctx context.Context, id string) error {
+		return f.Stop(ctx, StopMachineInput{ID: id})
+	})
+}
+
+// StartMachines is like StopMachines, but starts the machines.
+func (f *Client) StartMachines(ctx context.Context, ids []string, concurrency int) map[string]error {
+	return forEachMachine(ctx, ids, concurrency, func(ctx context.Context, id string) error {
+		_, err := f.Start(ctx, id)
+		return err
+	})
+}
+
+// GetMany fetches the given machines, running at most concurrency gets at
+// once. Each ID lands in exactly one of the returned maps; machines that
+// don't exist are in the error map with an error satisfying IsNotFound.
+func (f *Client) GetMany(ctx context.Context, ids []string, concurrency int) (map[string]*Machine, map[string]error) {
+	var (
+		mu       sync.Mutex
+		machines = make(map[string]*Machine, len(ids))
+	)
+	results := forEachMachine(ctx, ids, concurrency, func(ctx context.Context, id string) error {
+		machine, err := f.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		machines[id] = machine
+		mu.Unlock()
+		return nil
+	})
+
+	errs := make(map[string]error)
+	for id, err := range results {
+		if err != nil {
+			errs[id] = err
+		}
+	}
+	return machines, errs
+}
+
+type DestroyAllOptions struct {
+	// Confirm must be set for DestroyAll to do anything, to guard against
+	// calling it by accident.
+	Confirm bool
+	// Kill destroys running machines too, instead of failing on them.
+	Kill bool
+	// Concurrency caps the destroys in flight at once. It defaults to 1.
+	Concurrency int
+}
+
+// DestroyAll destroys every machine of the client's app. It returns each
+// machine's outcome, as StopMachines does, so that failures can be retried,
+// and an error joining the failures.
+func (f *Client) DestroyAll(ctx context.Context, opts DestroyAllOptions) (map[string]error, error) {
+	if !opts.Confirm {
+		return nil, errors.New("failed to destroy all VMs: DestroyAllOptions.Confirm is not set")
+	}
+
+	var ids []string
+	for machine, err := range f.ListAll(ctx, ListFilter{}) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to destroy all VMs: %w", err)
+		}
+		ids = append(ids, machine.ID)
+	}
+
+	results := forEachMachine(ctx, ids, opts.Concurrency, func(ctx context.Context, id string) error {
+		return f.Destroy(ctx, RemoveMachineInput{ID: id, Kill: opts.Kill})
+	})
+
+	var errs []error
+	for _, id := range ids {
+		if err := results[id]; err != nil {
+			errs = append(errs, fmt.Errorf("machine %s: %w", id, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// WaitAll waits for all of machines to reach state at once, each within
+// timeout as for Wait. The error joins the failure of every machine that
+// didn't get there, each naming its machine.
+func (f *Client) WaitAll(ctx context.Context, machines []*Machine, state MachineState, timeout time.Duration) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(machines))
+	)
+	for i, machine := range machines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = f.Wait(ctx, machine, state, timeout)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// forEachMachine calls fn for each of ids from a pool of concurrency workers
+// and collects the results by ID.
+func forEachMachine(ctx context.Context, ids []string, concurrency int, fn func(context.Context, string) error) map[string]error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error, len(ids))
+		work    = make(chan string)
+	)
+
+	for i := 0; i < concurrency && i < len(ids); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				err := fn(ctx, id)
+				mu.Lock()
+				results[id] = err
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var skipped []string
+dispatch:
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			skipped = ids[i:]
+			break
+		}
+		select {
+		case work <- id:
+		case <-ctx.Done():
+			skipped = ids[i:]
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	for _, id := range skipped {
+		results[id] = ctx.Err()
+	}
+	return results
+}
+
+type LaunchManyOptions struct {
+	// Concurrency caps the launches in flight at once. It defaults to
+	// launching all inputs at once.
+	Concurrency int
+	// RollbackOnError cancels the remaining launches after the first failure
+	// and destroys the machines already launched.
+	RollbackOnError bool
+}
+
+// LaunchMany launches a machine for each input concurrently. The returned
+// machines line up with inputs and are nil where a launch failed or was
+// rolled back. The error joins every launch and rollback failure.
+func (f *Client) LaunchMany(ctx context.Context, inputs []LaunchMachineInput, opts LaunchManyOptions) ([]*Machine, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 || concurrency > len(inputs) {
+		concurrency = len(inputs)
+	}
+
+	launchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		machines = make([]*Machine, len(inputs))
+		errs     = make([]error, len(inputs))
+		sem      = make(chan struct{}, concurrency)
+	)
+
+	for i := range inputs {
+		select {
+		case sem <- struct{}{}:
+		case <-launchCtx.Done():
+			errs[i] = fmt.Errorf("machine %d not launched: %w", i, launchCtx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			machine, err := f.Launch(launchCtx, inputs[i])
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[i] = fmt.Errorf("machine %d: %w", i, err)
+				if opts.RollbackOnError {
+					cancel()
+				}
+				return
+			}
+			machines[i] = machine
+		}(i)
+	}
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	if err == nil || !opts.RollbackOnError {
+		return machines, err
+	}
+
+	rollbackCtx, cancelRollback := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancelRollback()
+
+	rollbackErrs := []error{err}
+	for i, machine := range machines {
+		if machine == nil {
+			continue
+		}
+		if destroyErr := f.Destroy(rollbackCtx, RemoveMachineInput{ID: machine.ID, Kill: true}); destroyErr != nil {
+			rollbackErrs = append(rollbackErrs, fmt.Errorf("rolling back machine %d: %w", i, destroyErr))
+			continue
+		}
+		machines[i] = nil
+	}
+	return machines, errors.Join(rollbackErrs...)
+}
@@ -0,0 +1,121 @@
+package flaps_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+// launchInputs returns n launch inputs, of which the one at index fail has
+// no config, so that the server rejects it.
+func launchInputs(n, fail int) []flaps.LaunchMachineInput {
+	inputs := make([]flaps.LaunchMachineInput, n)
+	for i := range inputs {
+		if i != fail {
+			inputs[i].Config = &flaps.MachineConfig{Image: "nginx"}
+		}
+	}
+	return inputs
+}
+
+// unvalidatedClient returns a client of server that sends invalid inputs on
+// to it instead of rejecting them itself.
+func unvalidatedClient(t *testing.T, server *flapstest.Server) *flaps.Client {
+	t.Helper()
+	client, err := server.NewClient(flapstest.AppName, flaps.WithoutValidation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestLaunchManyRollsBack(t *testing.T) {
+	server, _ := flapstest.NewServer()
+	defer server.Close()
+	client := unvalidatedClient(t, server)
+	ctx := context.Background()
+
+	machines, err := client.LaunchMany(ctx, launchInputs(4, 2), flaps.LaunchManyOptions{Concurrency: 1, RollbackOnError: true})
+	if err == nil || !strings.Contains(err.Error(), "machine 2:") {
+		t.Fatalf("got error %v, want the failure of machine 2", err)
+	}
+	for i, machine := range machines {
+		if machine != nil {
+			t.Errorf("machine %d wasn't rolled back: %+v", i, machine)
+		}
+	}
+
+	launched, _, err := client.ListPage(ctx, flaps.ListFilter{IncludeDeleted: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(launched) < 2 {
+		t.Fatalf("got %d machines launched, want at least the 2 before the failure", len(launched))
+	}
+	for _, machine := range launched {
+		if machine.State != "destroyed" {
+			t.Errorf("machine %s was left %s, want it destroyed", machine.ID, machine.State)
+		}
+	}
+}
+
+func TestLaunchManyWithoutRollback(t *testing.T) {
+	server, _ := flapstest.NewServer()
+	defer server.Close()
+	client := unvalidatedClient(t, server)
+
+	machines, err := client.LaunchMany(context.Background(), launchInputs(2, 1), flaps.LaunchManyOptions{Concurrency: 1})
+	if err == nil || !strings.Contains(err.Error(), "machine 1:") {
+		t.Fatalf("got error %v, want the failure of machine 1", err)
+	}
+	if machines[0] == nil || machines[1] != nil {
+		t.Fatalf("got machines %v, want only the first", machines)
+	}
+	if machine, _ := server.Machine(machines[0].ID); machine.State != "started" {
+		t.Fatalf("got machine %s %s, want it left started", machine.ID, machine.State)
+	}
+}
+
+func TestDestroyAllRequiresConfirm(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	machine := launchMachine(t, client)
+
+	results, err := client.DestroyAll(context.Background(), flaps.DestroyAllOptions{Kill: true})
+	if err == nil || results != nil {
+		t.Fatalf("got results %v and error %v, want only an error", results, err)
+	}
+	if got, _ := server.Machine(machine.ID); got.State == "destroyed" {
+		t.Fatal("DestroyAll destroyed a machine without Confirm")
+	}
+}
+
+func TestDestroyAll(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	started := launchMachine(t, client)
+	stopped := launchMachine(t, client)
+	if err := client.Stop(ctx, flaps.StopMachineInput{ID: stopped.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without Kill the started machine can't be destroyed.
+	results, err := client.DestroyAll(ctx, flaps.DestroyAllOptions{Confirm: true, Concurrency: 2})
+	if err == nil || !strings.Contains(err.Error(), started.ID) {
+		t.Fatalf("got error %v, want the failure of %s", err, started.ID)
+	}
+	if len(results) != 2 || results[stopped.ID] != nil || results[started.ID] == nil {
+		t.Fatalf("got results %v, want %s destroyed and %s failed", results, stopped.ID, started.ID)
+	}
+	if got, _ := server.Machine(stopped.ID); got.State != "destroyed" {
+		t.Errorf("got machine %s %s, want it destroyed", stopped.ID, got.State)
+	}
+	if got, _ := server.Machine(started.ID); got.State != "started" {
+		t.Errorf("got machine %s %s, want it left started", started.ID, got.State)
+	}
+}
@@ -0,0 +1,64 @@
+package flaps
+
+import "net/http"
+
+// IdempotencyKeyHeader is the header WithIdempotencyKey sends its key in.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// CallOption customizes a single API call.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	nonce   string
+	headers http.Header
+}
+
+// WithNonce sends the nonce of a lease held on the machine, which mutating
+// calls on a leased machine need. Without it they fail with an error
+// satisfying IsConflict.
+func WithNonce(nonce string) CallOption {
+	return func(o *callOptions) {
+		o.nonce = nonce
+	}
+}
+
+// WithHeader adds a header to the request, e.g. fly-force-instance-id or an
+// idempotency key. Authorization and Content-Type are managed by the client
+// and can't be set this way.
+func WithHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		switch http.CanonicalHeaderKey(key) {
+		case "Authorization", "Content-Type":
+			return
+		}
+		if o.headers == nil {
+			o.headers = make(http.Header)
+		}
+		o.headers.Add(key, value)
+	}
+}
+
+// WithIdempotencyKey sends key in the Idempotency-Key header, for servers
+// that return the result of the first request bearing a key instead of
+// acting on it again. Flaps doesn't document doing so. Requests carrying a
+// key are only retried under IdempotentRetryPolicy.
+func WithIdempotencyKey(key string) CallOption {
+	return WithHeader(IdempotencyKeyHeader, key)
+}
+
+// callHeaders returns the request headers opts call for.
+func (f *Client) callHeaders(opts []CallOption) map[string][]string {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	headers := make(map[string][]string)
+	for key, values := range o.headers {
+		headers[key] = append([]string(nil), values...)
+	}
+	if o.nonce != "" {
+		headers[f.nonceHeader] = []string{o.nonce}
+	}
+	return headers
+}
@@ -0,0 +1,84 @@
+package flaps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CloneOptions override what Clone copies from the source machine. Zero
+// fields keep the source's values.
+type CloneOptions struct {
+	Name   string
+	Region string
+	Image  string
+	// Env is merged over the source's environment.
+	Env map[string]string
+	// Mounts replaces the source's mounts, which are dropped otherwise since
+	// a volume can only be attached to one machine.
+	Mounts []MachineMount
+}
+
+// Clone launches a new machine with the config of the source machine, with
+// overrides applied. The new machine gets its own identity: only the config
+// and region are carried over, and the name only if given in overrides.
+func (f *Client) Clone(ctx context.Context, sourceID string, overrides CloneOptions, opts ...CallOption) (*Machine, error) {
+	source, err := f.Get(ctx, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone VM %s: %w", sourceID, err)
+	}
+	if source.Config == nil {
+		return nil, fmt.Errorf("failed to clone VM %s: it has no config", sourceID)
+	}
+
+	input, err := cloneInput(source, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone VM %s: %w", sourceID, err)
+	}
+	return f.Launch(ctx, input, opts...)
+}
+
+func cloneInput(source *Machine, overrides CloneOptions) (LaunchMachineInput, error) {
+	config, err := copyConfig(source.Config)
+	if err != nil {
+		return LaunchMachineInput{}, err
+	}
+
+	config.Mounts = overrides.Mounts
+	if overrides.Image != "" {
+		config.Image = overrides.Image
+	}
+	if len(overrides.Env) > 0 {
+		if config.Env == nil {
+			config.Env = make(map[string]string, len(overrides.Env))
+		}
+		for k, v := range overrides.Env {
+			config.Env[k] = v
+		}
+	}
+
+	input := LaunchMachineInput{
+		Region: source.Region,
+		Config: config,
+	}
+	if overrides.Name != "" {
+		input.Name = overrides.Name
+	}
+	if overrides.Region != "" {
+		input.Region = overrides.Region
+	}
+	return input, nil
+}
+
+// copyConfig returns a deep copy of config.
+func copyConfig(config *MachineConfig) (*MachineConfig, error) {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	out := new(MachineConfig)
+	if err := json.Unmarshal(b, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,71 @@
+package flaps_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+func TestClone(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	source, err := client.Launch(ctx, flaps.LaunchMachineInput{
+		Name:   "web-1",
+		Region: "iad",
+		Config: &flaps.MachineConfig{
+			Image:  "nginx:1",
+			Env:    map[string]string{"PORT": "8080", "MODE": "primary"},
+			Mounts: []flaps.MachineMount{{Path: "/data", Volume: "vol_1"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("overrides", func(t *testing.T) {
+		clone, err := client.Clone(ctx, source.ID, flaps.CloneOptions{
+			Name:   "web-2",
+			Region: "ord",
+			Image:  "nginx:2",
+			Env:    map[string]string{"MODE": "replica"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if clone.Name != "web-2" || clone.Region != "ord" || clone.Config.Image != "nginx:2" {
+			t.Errorf("got name %q, region %q, image %q, want the overrides", clone.Name, clone.Region, clone.Config.Image)
+		}
+		if want := map[string]string{"PORT": "8080", "MODE": "replica"}; !reflect.DeepEqual(clone.Config.Env, want) {
+			t.Errorf("got env %v, want %v", clone.Config.Env, want)
+		}
+		if len(clone.Config.Mounts) != 0 {
+			t.Errorf("got mounts %+v, want none", clone.Config.Mounts)
+		}
+
+		got, _ := server.Machine(source.ID)
+		if got.Config.Env["MODE"] != "primary" {
+			t.Errorf("cloning changed the source's env to %v", got.Config.Env)
+		}
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		clone, err := client.Clone(ctx, source.ID, flaps.CloneOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if clone.ID == source.ID || clone.InstanceID == source.InstanceID || clone.PrivateIP == source.PrivateIP {
+			t.Errorf("clone shares the id, instance id or private ip of the source: %+v", clone)
+		}
+		if clone.Name == source.Name {
+			t.Errorf("clone was given the source's name %q", clone.Name)
+		}
+		if clone.Region != source.Region || clone.Config.Image != source.Config.Image {
+			t.Errorf("got region %q, image %q, want the source's", clone.Region, clone.Config.Image)
+		}
+	})
+}
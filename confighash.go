@@ -0,0 +1,49 @@
+package flaps
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+// ConfigHash returns a hash of the input's config, for comparison with the
+// ConfigHash of a running machine to skip updates that change nothing.
+func (in LaunchMachineInput) ConfigHash() (string, error) {
+	return hashConfig(in.Config)
+}
+
+// ConfigHash returns a hash of the machine's config. See
+// LaunchMachineInput.ConfigHash.
+func (m Machine) ConfigHash() (string, error) {
+	return hashConfig(m.Config)
+}
+
+// hashConfig hashes the canonical JSON encoding of config: object keys are
+// sorted and fields left out by omitempty don't count, so configs that
+// encode the same hash the same.
+func hashConfig(config *MachineConfig) (string, error) {
+	if config == nil {
+		return "", errors.New("failed to hash config: no config")
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	// Decoding into generic values and encoding again sorts the keys of
+	// struct fields too, not just those of maps.
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var canonical interface{}
+	if err := decoder.Decode(&canonical); err != nil {
+		return "", err
+	}
+	if b, err = json.Marshal(canonical); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
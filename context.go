@@ -1,9 +1,14 @@
 package flaps
 
-import "context"
+import (
+	"context"
+	"net/http"
+)
 
 type contextKey struct{}
 
+type responseKey struct{}
+
 // NewContext derives a Context that carries c from ctx.
 func NewContext(ctx context.Context, c *Client) context.Context {
 	return context.WithValue(ctx, contextKey{}, c)
@@ -14,3 +19,27 @@ func NewContext(ctx context.Context, c *Client) context.Context {
 func FromContext(ctx context.Context) *Client {
 	return ctx.Value(contextKey{}).(*Client)
 }
+
+// Response describes the HTTP response to an API call.
+type Response struct {
+	StatusCode int
+	// RequestID is the fly-request-id Fly support can correlate the call with.
+	RequestID string
+	Headers   http.Header
+}
+
+// CaptureResponse derives a Context from ctx that makes API calls made with it
+// record their final response into resp, whether or not they succeed.
+func CaptureResponse(ctx context.Context, resp *Response) context.Context {
+	return context.WithValue(ctx, responseKey{}, resp)
+}
+
+func captureResponse(ctx context.Context, resp *http.Response) {
+	if captured, ok := ctx.Value(responseKey{}).(*Response); ok && captured != nil {
+		*captured = Response{
+			StatusCode: resp.StatusCode,
+			RequestID:  resp.Header.Get(requestIDHeader),
+			Headers:    resp.Header.Clone(),
+		}
+	}
+}
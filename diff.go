@@ -0,0 +1,94 @@
+package flaps
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ConfigChange is a field that differs between two configs. Path names the
+// field by its JSON keys, such as "guest.memory_mb", "env.PORT" or
+// "services[0].internal_port". Old is nil for fields desired adds and New is
+// nil for fields it removes. Values are as decoded from JSON.
+type ConfigChange struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (c ConfigChange) String() string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("+ %s: %v", c.Path, c.New)
+	case c.New == nil:
+		return fmt.Sprintf("- %s: %v", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %v -> %v", c.Path, c.Old, c.New)
+	}
+}
+
+// DiffConfig returns the fields that would change if current were replaced by
+// desired, sorted by path. Objects are compared field by field and arrays
+// element by element, so a changed service port shows up as that one field.
+// A config that can't be encoded is reported as a single change with an
+// empty path.
+func DiffConfig(current, desired MachineConfig) []ConfigChange {
+	currentValue, err := jsonValue(current)
+	if err != nil {
+		return []ConfigChange{{Old: current, New: desired}}
+	}
+	desiredValue, err := jsonValue(desired)
+	if err != nil {
+		return []ConfigChange{{Old: current, New: desired}}
+	}
+
+	var changes []ConfigChange
+	diffJSON("", currentValue, desiredValue, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func diffJSON(path string, old, new interface{}, changes *[]ConfigChange) {
+	oldObject, oldIsObject := old.(map[string]interface{})
+	newObject, newIsObject := new.(map[string]interface{})
+	// A side that is missing counts as empty, so fields added or removed
+	// whole are listed leaf by leaf.
+	if (oldIsObject || old == nil) && (newIsObject || new == nil) && (oldIsObject || newIsObject) {
+		for key, value := range oldObject {
+			diffJSON(joinPath(path, key), value, newObject[key], changes)
+		}
+		for key, value := range newObject {
+			if _, ok := oldObject[key]; !ok {
+				diffJSON(joinPath(path, key), nil, value, changes)
+			}
+		}
+		return
+	}
+
+	oldArray, oldIsArray := old.([]interface{})
+	newArray, newIsArray := new.([]interface{})
+	if (oldIsArray || old == nil) && (newIsArray || new == nil) && (oldIsArray || newIsArray) {
+		for i := 0; i < len(oldArray) || i < len(newArray); i++ {
+			var oldElem, newElem interface{}
+			if i < len(oldArray) {
+				oldElem = oldArray[i]
+			}
+			if i < len(newArray) {
+				newElem = newArray[i]
+			}
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), oldElem, newElem, changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, ConfigChange{Path: path, Old: old, New: new})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
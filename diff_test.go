@@ -0,0 +1,81 @@
+package flaps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffConfig(t *testing.T) {
+	current := MachineConfig{
+		Image:    "nginx:1",
+		Env:      map[string]string{"PORT": "8080", "MODE": "primary"},
+		Guest:    &MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: 256},
+		Services: []MachineService{{Protocol: "tcp", InternalPort: 8080, Ports: []MachinePort{{Port: 80}, {Port: 443}}}},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*MachineConfig)
+		want   []ConfigChange
+	}{
+		{"unchanged", func(*MachineConfig) {}, nil},
+		{
+			"added",
+			func(c *MachineConfig) { c.Env["DEBUG"] = "1" },
+			[]ConfigChange{{Path: "env.DEBUG", New: "1"}},
+		},
+		{
+			"removed",
+			func(c *MachineConfig) { c.Guest = nil },
+			[]ConfigChange{
+				{Path: "guest.cpu_kind", Old: "shared"},
+				{Path: "guest.cpus", Old: float64(1)},
+				{Path: "guest.memory_mb", Old: float64(256)},
+			},
+		},
+		{
+			"changed nested",
+			func(c *MachineConfig) {
+				c.Services[0].Ports[1].Port = 8443
+				c.Guest.MemoryMB = 512
+			},
+			[]ConfigChange{
+				{Path: "guest.memory_mb", Old: float64(256), New: float64(512)},
+				{Path: "services[0].ports[1].port", Old: float64(443), New: float64(8443)},
+			},
+		},
+		{
+			"removed array element",
+			func(c *MachineConfig) { c.Services[0].Ports = c.Services[0].Ports[:1] },
+			[]ConfigChange{{Path: "services[0].ports[1].port", Old: float64(443)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired, err := copyConfig(&current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(desired)
+			if got := DiffConfig(current, *desired); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got changes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigChangeString(t *testing.T) {
+	tests := []struct {
+		change ConfigChange
+		want   string
+	}{
+		{ConfigChange{Path: "env.DEBUG", New: "1"}, "+ env.DEBUG: 1"},
+		{ConfigChange{Path: "env.DEBUG", Old: "1"}, "- env.DEBUG: 1"},
+		{ConfigChange{Path: "image", Old: "nginx:1", New: "nginx:2"}, "~ image: nginx:1 -> nginx:2"},
+	}
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package flaps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// EnsureMachine makes sure a machine called name exists with input's config,
+// for reconcilers that may retry after a launch whose response was lost. It
+// returns the machine and whether it was created:
+//
+//   - if no machine is called name, one is launched from input;
+//   - if one is and its config already matches input's, it is returned as is;
+//   - otherwise it is updated with input's config.
+//
+// A config matches when every field set in input's config has the same value
+// on the machine. Fields input leaves unset are ignored, as the server fills
+// in defaults for them, except that env and metadata must match exactly, and
+// leaving them unset asks for none. The region of an existing machine can't
+// change and isn't compared. Input's name is set to name.
+func (f *Client) EnsureMachine(ctx context.Context, name string, input LaunchMachineInput) (*Machine, bool, error) {
+	if input.Config == nil {
+		return nil, false, fmt.Errorf("failed to ensure VM %s: config is required", name)
+	}
+	input.Name = name
+
+	existing, err := f.GetMachineByName(ctx, name)
+	switch {
+	case IsNotFound(err):
+		machine, err := f.Launch(ctx, input)
+		if err != nil {
+			return nil, false, err
+		}
+		return machine, true, nil
+	case err != nil:
+		return nil, false, fmt.Errorf("failed to ensure VM %s: %w", name, err)
+	}
+
+	matches, err := configMatches(existing.Config, input.Config)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to ensure VM %s: %w", name, err)
+	}
+	if matches {
+		return existing, false, nil
+	}
+
+	input.ID = existing.ID
+	input.Region = existing.Region
+	machine, err := f.Update(ctx, input, "")
+	if err != nil {
+		return nil, false, err
+	}
+	return machine, false, nil
+}
+
+// exactConfigFields are the config fields configMatches compares as a whole,
+// since they hold user data rather than settings the server adds to.
+var exactConfigFields = map[string]bool{
+	"env":      true,
+	"metadata": true,
+}
+
+// configMatches reports whether every field set in desired has the same value
+// in current, comparing their JSON encodings.
+func configMatches(current, desired *MachineConfig) (bool, error) {
+	if current == nil {
+		return false, nil
+	}
+	currentValue, err := jsonValue(current)
+	if err != nil {
+		return false, err
+	}
+	desiredValue, err := jsonValue(desired)
+	if err != nil {
+		return false, err
+	}
+
+	currentFields, _ := currentValue.(map[string]interface{})
+	desiredFields := desiredValue.(map[string]interface{})
+	for key := range exactConfigFields {
+		// Empty maps are left out of the encoding, so a missing field is
+		// compared as empty rather than skipped.
+		got, _ := currentFields[key].(map[string]interface{})
+		want, _ := desiredFields[key].(map[string]interface{})
+		if len(got) != 0 || len(want) != 0 {
+			if !reflect.DeepEqual(got, want) {
+				return false, nil
+			}
+		}
+	}
+	for key, want := range desiredFields {
+		if exactConfigFields[key] {
+			continue
+		}
+		got, ok := currentFields[key]
+		if !ok || !jsonCovers(got, want) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// jsonCovers reports whether got has every field of want with the same value,
+// recursing into objects. Arrays and scalars must be equal.
+func jsonCovers(got, want interface{}) bool {
+	wantObject, ok := want.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(got, want)
+	}
+	gotObject, ok := got.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, value := range wantObject {
+		if !jsonCovers(gotObject[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonValue returns v as decoded from its JSON encoding into generic values.
+func jsonValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
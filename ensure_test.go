@@ -0,0 +1,62 @@
+package flaps_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+func TestEnsureMachine(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	input := flaps.LaunchMachineInput{Config: &flaps.MachineConfig{
+		Image: "nginx",
+		Env:   map[string]string{"A": "1"},
+	}}
+	created, wasCreated, err := client.EnsureMachine(ctx, "web", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wasCreated {
+		t.Fatal("first call didn't create the machine")
+	}
+
+	same, wasCreated, err := client.EnsureMachine(ctx, "web", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wasCreated || same.ID != created.ID || same.InstanceID != created.InstanceID {
+		t.Fatalf("matching config: got created=%v, machine %s/%s, want %s/%s unchanged", wasCreated, same.ID, same.InstanceID, created.ID, created.InstanceID)
+	}
+
+	tests := []struct {
+		name   string
+		config flaps.MachineConfig
+	}{
+		{"image changed", flaps.MachineConfig{Image: "nginx:1.27", Env: map[string]string{"A": "1"}}},
+		{"env changed", flaps.MachineConfig{Image: "nginx", Env: map[string]string{"A": "2"}}},
+		{"env removed", flaps.MachineConfig{Image: "nginx"}},
+		{"metadata added", flaps.MachineConfig{Image: "nginx", Metadata: map[string]string{"role": "web"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			updated, wasCreated, err := client.EnsureMachine(ctx, "web", flaps.LaunchMachineInput{Config: &config})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wasCreated || updated.ID != created.ID {
+				t.Fatalf("got created=%v, machine %s, want %s updated", wasCreated, updated.ID, created.ID)
+			}
+
+			stored, _ := server.Machine(created.ID)
+			if got := flaps.DiffConfig(*stored.Config, config); len(got) != 0 {
+				t.Fatalf("machine not updated to the desired config: %v", got)
+			}
+		})
+	}
+}
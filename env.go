@@ -0,0 +1,57 @@
+package flaps
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// PublicBaseURL is the base URL of the public Machines API.
+const PublicBaseURL = "https://api.machines.dev/v1"
+
+// NewFromEnv returns a Client for appName configured from the environment:
+// the token comes from FLY_API_TOKEN, and the endpoint from FLY_API_BASE_URL,
+// or else FLY_API_HOSTNAME, or else defaults to PublicBaseURL. opts are
+// applied on top.
+//
+// FLY_API_HOSTNAME is normally a URL such as "https://api.machines.dev", to
+// which "/v1" is added when it has no path. A bare host name is reached like
+// WithHost does, over http on the internal Flaps port.
+func NewFromEnv(appName string, opts ...Option) (*Client, error) {
+	token := os.Getenv("FLY_API_TOKEN")
+	if token == "" {
+		return nil, errors.New("FLY_API_TOKEN is not set")
+	}
+
+	envOpts := []Option{WithToken(token), WithApp(appName)}
+	switch {
+	case os.Getenv("FLY_API_BASE_URL") != "":
+		envOpts = append(envOpts, WithBaseURL(os.Getenv("FLY_API_BASE_URL")))
+	case os.Getenv("FLY_API_HOSTNAME") != "":
+		opt, err := hostnameOption(os.Getenv("FLY_API_HOSTNAME"))
+		if err != nil {
+			return nil, err
+		}
+		envOpts = append(envOpts, opt)
+	default:
+		envOpts = append(envOpts, WithBaseURL(PublicBaseURL))
+	}
+
+	return NewClient(append(envOpts, opts...)...)
+}
+
+func hostnameOption(hostname string) (Option, error) {
+	if !strings.Contains(hostname, "://") {
+		return WithHost(hostname), nil
+	}
+	u, err := url.Parse(hostname)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("FLY_API_HOSTNAME %q is neither a host name nor a URL", hostname)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1"
+	}
+	return WithBaseURL(u.String()), nil
+}
@@ -0,0 +1,47 @@
+package flaps
+
+import "testing"
+
+func TestNewFromEnvEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		hostname string
+		want     string
+	}{
+		{name: "default", want: PublicBaseURL},
+		{name: "base url", baseURL: "https://flaps.example.com/v1/", want: "https://flaps.example.com/v1"},
+		{name: "base url over hostname", baseURL: "https://flaps.example.com/v1", hostname: "https://api.machines.dev", want: "https://flaps.example.com/v1"},
+		{name: "hostname url", hostname: "https://api.machines.dev", want: "https://api.machines.dev/v1"},
+		{name: "hostname url with path", hostname: "https://api.machines.dev/v1", want: "https://api.machines.dev/v1"},
+		{name: "bare hostname", hostname: "_api.internal", want: "http://_api.internal:4280/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FLY_API_TOKEN", "token")
+			t.Setenv("FLY_API_BASE_URL", tt.baseURL)
+			t.Setenv("FLY_API_HOSTNAME", tt.hostname)
+
+			client, err := NewFromEnv("app")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := client.BaseURL(); got != tt.want {
+				t.Fatalf("BaseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFromEnvErrors(t *testing.T) {
+	t.Setenv("FLY_API_TOKEN", "")
+	if _, err := NewFromEnv("app"); err == nil {
+		t.Error("got no error without FLY_API_TOKEN")
+	}
+
+	t.Setenv("FLY_API_TOKEN", "token")
+	t.Setenv("FLY_API_HOSTNAME", "https://")
+	if _, err := NewFromEnv("app"); err == nil {
+		t.Error("got no error for a URL without a host")
+	}
+}
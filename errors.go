@@ -0,0 +1,135 @@
+package flaps
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the client's
+// size limit.
+var ErrResponseTooLarge = errors.New("response body exceeds the size limit")
+
+// ErrVersionMismatch is returned by UpdateWithOptions when the machine is no
+// longer at the expected version.
+var ErrVersionMismatch = errors.New("machine version changed")
+
+// ErrDryRun is returned by mutating calls of clients created with
+// WithDryRun. DryRunRequest recovers the request that wasn't sent.
+var ErrDryRun = errors.New("dry run: request not sent")
+
+type dryRunError struct {
+	req *http.Request
+}
+
+func (e *dryRunError) Error() string { return ErrDryRun.Error() }
+
+func (e *dryRunError) Unwrap() error { return ErrDryRun }
+
+// DryRunRequest returns the request a dry run call built instead of sending
+// it, if err is from one. Its body is unread. It carries the Authorization
+// header, which should be redacted before the request is shown to anyone.
+func DryRunRequest(err error) (*http.Request, bool) {
+	var dryRunErr *dryRunError
+	if errors.As(err, &dryRunErr) {
+		return dryRunErr.req, true
+	}
+	return nil, false
+}
+
+// ErrMachineNotFound is returned by lookups that find no matching machine
+// without the server reporting a 404, such as GetMachineByName.
+var ErrMachineNotFound = errors.New("machine not found")
+
+// FlapsError is returned for responses outside the 2xx range.
+type FlapsError struct {
+	StatusCode   int
+	ResponseBody []byte
+	// RequestID is the fly-request-id of the response, for Fly support.
+	RequestID string
+
+	// APIError and Message are parsed from the response body, when it is
+	// JSON. Otherwise the error message quotes the start of the raw body.
+	APIError string
+	Message  string
+
+	// RetryAfter is how long the server asked to wait before retrying, from
+	// the Retry-After header of the response.
+	RetryAfter time.Duration
+}
+
+func (e *FlapsError) Error() string {
+	switch {
+	case e.Message != "":
+		return e.Message
+	case e.APIError != "":
+		return e.APIError
+	case e.StatusCode/100 == 4 || e.StatusCode/100 == 5:
+		if snippet := bodySnippet(e.ResponseBody); snippet != "" {
+			return fmt.Sprintf("request returned non-2xx status, %d: %s", e.StatusCode, snippet)
+		}
+		return fmt.Sprintf("request returned non-2xx status, %d", e.StatusCode)
+	case e.StatusCode/100 == 1 || e.StatusCode/100 == 3:
+		return fmt.Sprintf("API returned unexpected status, %d", e.StatusCode)
+	default:
+		return "something went terribly wrong"
+	}
+}
+
+const maxBodySnippet = 256
+
+// bodySnippet returns the start of body, trimmed to fit in an error message.
+// Invalid UTF-8 is replaced rather than cut off.
+func bodySnippet(body []byte) string {
+	snippet := strings.TrimSpace(strings.ToValidUTF8(string(body), string(utf8.RuneError)))
+	if len(snippet) <= maxBodySnippet {
+		return snippet
+	}
+	end := maxBodySnippet
+	for !utf8.RuneStart(snippet[end]) {
+		end--
+	}
+	return snippet[:end] + "..."
+}
+
+// StatusCode returns the HTTP status code of the FlapsError in err's chain,
+// or 0 if there is none.
+func StatusCode(err error) int {
+	var flapsErr *FlapsError
+	if errors.As(err, &flapsErr) {
+		return flapsErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err was caused by a 404 response, or is
+// ErrMachineNotFound.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound || errors.Is(err, ErrMachineNotFound)
+}
+
+// IsUnauthorized reports whether err was caused by a 401 or 403 response,
+// meaning the token is wrong, expired or lacks access.
+func IsUnauthorized(err error) bool {
+	status := StatusCode(err)
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// IsConflict reports whether err was caused by a 409 response, as returned
+// when a lease is held by someone else.
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of the
+// response that caused err, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	var flapsErr *FlapsError
+	if errors.As(err, &flapsErr) && flapsErr.RetryAfter > 0 {
+		return flapsErr.RetryAfter, true
+	}
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package flaps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBodySnippet(t *testing.T) {
+	long := strings.Repeat("a", maxBodySnippet-1) + "é" + "tail"
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"short", "  Bad gateway\n", "Bad gateway"},
+		{"invalid utf-8", "Bad\xff gateway: upstream", "Bad� gateway: upstream"},
+		{"long", strings.Repeat("a", maxBodySnippet+10), strings.Repeat("a", maxBodySnippet) + "..."},
+		{"long, cut inside a rune", long, strings.Repeat("a", maxBodySnippet-1) + "..."},
+		{"long invalid utf-8", "Bad\xff " + strings.Repeat("b", maxBodySnippet), "Bad� " + strings.Repeat("b", maxBodySnippet-len("Bad� ")) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bodySnippet([]byte(tt.body)); got != tt.want {
+				t.Fatalf("bodySnippet(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"m1","name":"` + strings.Repeat("a", 1024) + `"}`))
+	}, WithMaxResponseBytes(512))
+
+	if _, err := client.Get(context.Background(), "m1"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("got error %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func TestNonJSONErrorBody(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("<html>upstream connect error</html>\n"))
+	})
+
+	_, err := client.Get(context.Background(), "m1")
+	var flapsErr *FlapsError
+	if !errors.As(err, &flapsErr) || flapsErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got error %v, want a FlapsError with status 500", err)
+	}
+	if !strings.Contains(err.Error(), "<html>upstream connect error</html>") {
+		t.Fatalf("error %q doesn't include the response body", err)
+	}
+}
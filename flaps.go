@@ -7,122 +7,417 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// NonceHeader is the header lease nonces are sent in by clients that don't
+// set one with WithNonceHeader. Its value is read when a client is created.
+//
+// Deprecated: use WithNonceHeader.
 var NonceHeader = "fly-machine-lease-nonce"
 
+const requestIDHeader = "fly-request-id"
+
 type Client struct {
-	orgSlug    string
-	appName    string
-	host       string
-	authToken  string
-	httpClient *http.Client
+	orgSlug     string
+	appName     string
+	host        string
+	scheme      string
+	baseURL     string
+	authToken   string
+	tokenSource TokenSource
+	httpClient  *http.Client
+	transport   http.RoundTripper
+	// ownsTransport is set when httpClient was created by the client rather
+	// than given to it.
+	ownsTransport bool
+
+	nonceHeader     string
+	userAgentSuffix string
+
+	maxAttempts int
+	retryBase   time.Duration
+	retryPolicy RetryPolicy
+
+	defaultTimeout     time.Duration
+	skipValidation     bool
+	dryRun             bool
+	allowUnknownStates bool
+	maxResponseBytes   int64
+
+	logger             Logger
+	instruments        []Instrument
+	requestMiddleware  []RequestMiddleware
+	responseMiddleware []ResponseMiddleware
+
+	limiter   Limiter
+	rateLimit *rateLimitTracker
+	nonces    *nonceCache
+	leases    *leaseSet
+}
+
+func New(host, authToken, orgSlug, appName string, opts ...Option) (*Client, error) {
+	return NewWithClient(host, authToken, orgSlug, appName, nil, opts...)
+}
+
+func NewWithClient(host, authToken, orgSlug, appName string, httpClient *http.Client, opts ...Option) (*Client, error) {
+	return NewClient(append([]Option{
+		WithHost(host),
+		WithToken(authToken),
+		WithOrg(orgSlug),
+		WithApp(appName),
+		WithHTTPClient(httpClient),
+	}, opts...)...)
+}
+
+// NewClient returns a Client configured by opts. A host (or base URL) and a
+// token (or token source) are required.
+func NewClient(opts ...Option) (*Client, error) {
+	c := &Client{
+		nonceHeader: NonceHeader,
+		rateLimit:   &rateLimitTracker{},
+		nonces:      &nonceCache{},
+		leases:      &leaseSet{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.host == "" && c.baseURL == "" {
+		return nil, errors.New("a host or base URL is required")
+	}
+	if c.tokenSource == nil {
+		if c.authToken == "" {
+			return nil, errors.New("an auth token is required")
+		}
+		c.tokenSource = StaticToken(c.authToken)
+	}
+	switch {
+	case c.transport != nil:
+		var httpClient http.Client
+		if c.httpClient != nil {
+			httpClient = *c.httpClient
+		}
+		httpClient.Transport = c.transport
+		c.httpClient = &httpClient
+	case c.httpClient == nil:
+		c.httpClient = newHTTPClient()
+		c.ownsTransport = true
+	}
+	return c, nil
 }
 
-func New(host, authToken, orgSlug, appName string) (*Client, error) {
-	return NewWithClient(host, authToken, orgSlug, appName, http.DefaultClient)
+// ForApp returns a client scoped to another app. It shares everything else
+// with f, including the HTTP client, token and rate limit state.
+func (f *Client) ForApp(appName string) *Client {
+	c := *f
+	c.appName = appName
+	return &c
 }
 
-func NewWithClient(host, authToken, orgSlug, appName string, httpClient *http.Client) (*Client, error) {
-	return &Client{
-		appName:    appName,
-		orgSlug:    orgSlug,
-		host:       host,
-		authToken:  authToken,
-		httpClient: httpClient,
-	}, nil
+// ForOrg returns a client scoped to another organization, sharing
+// everything else with f like ForApp does.
+func (f *Client) ForOrg(orgSlug string) *Client {
+	c := *f
+	c.orgSlug = orgSlug
+	return &c
 }
 
+// OrgSlug returns the organization the client is scoped to.
+func (f *Client) OrgSlug() string {
+	return f.orgSlug
+}
+
+// AppName returns the app the client manages machines of.
+func (f *Client) AppName() string {
+	return f.appName
+}
+
+// CreateApp creates an app in org, or in the client's organization if org is
+// empty.
 func (f *Client) CreateApp(ctx context.Context, name string, org string) (err error) {
+	if org == "" {
+		org = f.orgSlug
+	}
 	in := map[string]interface{}{
 		"app_name": name,
 		"org_slug": org,
 	}
 
-	err = f.sendRequest(ctx, http.MethodPost, "/apps", in, nil, nil)
+	err = f.sendAppRequest(ctx, "CreateApp", http.MethodPost, "/apps", in, nil, nil)
 	return
 }
 
-func (f *Client) Launch(ctx context.Context, builder LaunchMachineInput) (*Machine, error) {
+// GetApp returns the named app. An error satisfying IsNotFound is returned
+// when the app doesn't exist.
+func (f *Client) GetApp(ctx context.Context, name string) (*App, error) {
+	endpoint := fmt.Sprintf("/apps/%s", url.PathEscape(name))
+
+	out := new(App)
+
+	if err := f.sendAppRequest(ctx, "GetApp", http.MethodGet, endpoint, nil, out, nil); err != nil {
+		return nil, fmt.Errorf("failed to get app %s: %w", name, err)
+	}
+	return out, nil
+}
+
+func (f *Client) DeleteApp(ctx context.Context, name string) (err error) {
+	endpoint := fmt.Sprintf("/apps/%s", url.PathEscape(name))
+
+	if err := f.sendAppRequest(ctx, "DeleteApp", http.MethodDelete, endpoint, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete app %s: %w", name, err)
+	}
+	return
+}
+
+// ListApps returns the apps of the client's organization, following
+// pagination if the server uses it.
+func (f *Client) ListApps(ctx context.Context) ([]*App, error) {
+	if f.orgSlug == "" {
+		return nil, errors.New("failed to list apps: the client has no organization")
+	}
+
+	apps := make([]*App, 0)
+	cursor := ""
+	for {
+		query := url.Values{}
+		query.Set("org_slug", f.orgSlug)
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		page := struct {
+			Apps       []*App `json:"apps"`
+			NextCursor string `json:"next_cursor"`
+		}{}
+
+		if err := f.sendAppRequest(ctx, "ListApps", http.MethodGet, "/apps?"+query.Encode(), nil, &page, nil); err != nil {
+			return nil, fmt.Errorf("failed to list apps: %w", err)
+		}
+		apps = append(apps, page.Apps...)
+
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return apps, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (f *Client) Launch(ctx context.Context, builder LaunchMachineInput, opts ...CallOption) (*Machine, error) {
 	var endpoint string
 	if builder.ID != "" {
 		endpoint = fmt.Sprintf("/%s", builder.ID)
 	}
 
+	if !f.skipValidation {
+		if err := builder.Validate(); err != nil {
+			return nil, fmt.Errorf("failed to launch VM: %w", err)
+		}
+	}
+
 	out := new(Machine)
 
-	if err := f.sendRequest(ctx, http.MethodPost, endpoint, builder, out, nil); err != nil {
+	if err := f.sendRequest(ctx, "Launch", http.MethodPost, endpoint, builder, out, f.callHeaders(opts)); err != nil {
 		return nil, fmt.Errorf("failed to launch VM: %w", err)
 	}
 
 	return out, nil
 }
 
-func (f *Client) Update(ctx context.Context, builder LaunchMachineInput, nonce string) (*Machine, error) {
-	headers := make(map[string][]string)
+func (f *Client) Update(ctx context.Context, builder LaunchMachineInput, nonce string, opts ...CallOption) (*Machine, error) {
+	headers := f.callHeaders(opts)
 
 	if nonce != "" {
-		headers[NonceHeader] = []string{nonce}
+		headers[f.nonceHeader] = []string{nonce}
 	}
 
 	endpoint := fmt.Sprintf("/%s", builder.ID)
 
+	if !f.skipValidation {
+		if builder.ID == "" {
+			return nil, errors.New("failed to update VM: id is required")
+		}
+		if err := builder.Validate(); err != nil {
+			return nil, fmt.Errorf("failed to update VM %s: %w", builder.ID, err)
+		}
+	}
+
 	out := new(Machine)
 
-	if err := f.sendRequest(ctx, http.MethodPost, endpoint, builder, out, headers); err != nil {
+	if err := f.sendRequest(ctx, "Update", http.MethodPost, endpoint, builder, out, headers); err != nil {
 		return nil, fmt.Errorf("failed to update VM %s: %w", builder.ID, err)
 	}
 	return out, nil
 }
 
-func (f *Client) Start(ctx context.Context, machineID string) (*MachineStartResponse, error) {
+type UpdateOptions struct {
+	// ExpectedVersion makes the update fail with an error wrapping
+	// ErrVersionMismatch unless the machine is still at this version, its
+	// WaitInstanceID as last read.
+	ExpectedVersion string
+}
+
+// UpdateWithOptions is like Update, with opts applied. Flaps has no
+// precondition support, so ExpectedVersion is checked by fetching the machine
+// first. A change made between that check and the update goes unnoticed;
+// holding a lease, whose nonce is passed as nonce, closes that gap.
+func (f *Client) UpdateWithOptions(ctx context.Context, builder LaunchMachineInput, nonce string, opts UpdateOptions, callOpts ...CallOption) (*Machine, error) {
+	if opts.ExpectedVersion != "" {
+		if builder.ID == "" {
+			return nil, errors.New("failed to update VM: id is required")
+		}
+		current, err := f.Get(ctx, builder.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update VM %s: %w", builder.ID, err)
+		}
+		if version := current.WaitInstanceID(); version != opts.ExpectedVersion {
+			return nil, fmt.Errorf("failed to update VM %s: %w: expected %s, found %s", builder.ID, ErrVersionMismatch, opts.ExpectedVersion, version)
+		}
+	}
+	return f.Update(ctx, builder, nonce, callOpts...)
+}
+
+func (f *Client) Start(ctx context.Context, machineID string, opts ...CallOption) (*MachineStartResponse, error) {
 	startEndpoint := fmt.Sprintf("/%s/start", machineID)
 
 	out := new(MachineStartResponse)
 
-	if err := f.sendRequest(ctx, http.MethodPost, startEndpoint, nil, out, nil); err != nil {
+	if err := f.sendRequest(ctx, "Start", http.MethodPost, startEndpoint, nil, out, f.callHeaders(opts)); err != nil {
 		return nil, fmt.Errorf("failed to start VM %s: %w", machineID, err)
 	}
 	return out, nil
 }
 
-func (f *Client) Wait(ctx context.Context, machine *Machine, state string) (err error) {
-	waitEndpoint := fmt.Sprintf("/%s/wait", machine.ID)
+func (f *Client) Stop(ctx context.Context, machine StopMachineInput, opts ...CallOption) (err error) {
+	_, err = f.StopWithResponse(ctx, machine, opts...)
+	return
+}
 
-	version := machine.InstanceID
+// StopWithResponse is like Stop, but also returns the server's response.
+func (f *Client) StopWithResponse(ctx context.Context, machine StopMachineInput, opts ...CallOption) (*MachineStopResponse, error) {
+	stopEndpoint := fmt.Sprintf("/%s/stop", machine.ID)
 
-	if machine.Version != "" {
-		version = machine.Version
+	// The body is only sent when there is something in it, leaving the
+	// server's defaults alone otherwise.
+	var in interface{}
+	body := map[string]string{}
+	if machine.Signal.Signal != 0 {
+		name, ok := signalName(int(machine.Signal.Signal))
+		if !ok {
+			return nil, fmt.Errorf("failed to stop VM %s: unsupported signal %d", machine.ID, machine.Signal.Signal)
+		}
+		body["signal"] = name
+	}
+	if machine.Timeout > 0 {
+		body["timeout"] = machine.Timeout.String()
 	}
-	if version != "" {
-		waitEndpoint += fmt.Sprintf("?instance_id=%s&timeout=30", version)
-	} else {
-		waitEndpoint += "?timeout=30"
+	if len(body) > 0 {
+		in = body
 	}
 
-	if state == "" {
-		state = "started"
+	out := new(MachineStopResponse)
+
+	if err := f.sendRequest(ctx, "Stop", http.MethodPost, stopEndpoint, in, out, f.callHeaders(opts)); err != nil {
+		return nil, fmt.Errorf("failed to stop VM %s: %w", machine.ID, err)
 	}
+	return out, nil
+}
 
-	waitEndpoint += fmt.Sprintf("&state=%s", state)
+// Suspend freezes the machine's memory to disk. A subsequent Start resumes it,
+// which is faster than a cold start.
+func (f *Client) Suspend(ctx context.Context, machineID string, opts ...CallOption) (err error) {
+	suspendEndpoint := fmt.Sprintf("/%s/suspend", machineID)
 
-	if err := f.sendRequest(ctx, http.MethodGet, waitEndpoint, nil, nil, nil); err != nil {
-		return fmt.Errorf("failed to wait for VM %s in %s state: %w", machine.ID, state, err)
+	if err := f.sendRequest(ctx, "Suspend", http.MethodPost, suspendEndpoint, nil, nil, f.callHeaders(opts)); err != nil {
+		return fmt.Errorf("failed to suspend VM %s: %w", machineID, err)
 	}
 	return
 }
 
-func (f *Client) Stop(ctx context.Context, machine StopMachineInput) (err error) {
-	stopEndpoint := fmt.Sprintf("/%s/stop", machine.ID)
+func (f *Client) Restart(ctx context.Context, machineID string, opts *RestartOptions, callOpts ...CallOption) (err error) {
+	restartEndpoint := fmt.Sprintf("/%s/restart", machineID)
 
-	if err := f.sendRequest(ctx, http.MethodPost, stopEndpoint, nil, nil, nil); err != nil {
-		return fmt.Errorf("failed to stop VM %s: %w", machine.ID, err)
+	if opts != nil {
+		query := url.Values{}
+		if opts.Timeout > 0 {
+			query.Set("timeout", opts.Timeout.String())
+		}
+		if opts.ForceStop {
+			query.Set("force_stop", "true")
+		}
+		if opts.Signal != "" {
+			query.Set("signal", opts.Signal)
+		}
+		if len(query) > 0 {
+			restartEndpoint += "?" + query.Encode()
+		}
+	}
+
+	if err := f.sendRequest(ctx, "Restart", http.MethodPost, restartEndpoint, nil, nil, f.callHeaders(callOpts)); err != nil {
+		return fmt.Errorf("failed to restart VM %s: %w", machineID, err)
+	}
+	return
+}
+
+func (f *Client) Cordon(ctx context.Context, machineID string, opts ...CallOption) (err error) {
+	cordonEndpoint := fmt.Sprintf("/%s/cordon", machineID)
+
+	if err := f.sendRequest(ctx, "Cordon", http.MethodPost, cordonEndpoint, nil, nil, f.callHeaders(opts)); err != nil {
+		return fmt.Errorf("failed to cordon VM %s: %w", machineID, err)
+	}
+	return
+}
+
+func (f *Client) Uncordon(ctx context.Context, machineID string, opts ...CallOption) (err error) {
+	uncordonEndpoint := fmt.Sprintf("/%s/uncordon", machineID)
+
+	if err := f.sendRequest(ctx, "Uncordon", http.MethodPost, uncordonEndpoint, nil, nil, f.callHeaders(opts)); err != nil {
+		return fmt.Errorf("failed to uncordon VM %s: %w", machineID, err)
 	}
 	return
 }
 
-func (f *Client) Get(ctx context.Context, machineID string) (*Machine, error) {
+// Exec runs a command in the machine. A non-zero exit code is not an error;
+// it is reported on the response.
+func (f *Client) Exec(ctx context.Context, machineID string, cmd ExecRequest, opts ...CallOption) (*ExecResponse, error) {
+	execEndpoint := fmt.Sprintf("/%s/exec", machineID)
+
+	out := new(ExecResponse)
+
+	if err := f.sendRequest(ctx, "Exec", http.MethodPost, execEndpoint, cmd, out, f.callHeaders(opts)); err != nil {
+		return nil, fmt.Errorf("failed to exec on VM %s: %w", machineID, err)
+	}
+	return out, nil
+}
+
+func (f *Client) ListProcesses(ctx context.Context, machineID string, sortBy, order string) ([]*Process, error) {
+	psEndpoint := fmt.Sprintf("/%s/ps", machineID)
+
+	query := url.Values{}
+	if sortBy != "" {
+		query.Set("sort_by", sortBy)
+	}
+	if order != "" {
+		query.Set("order", order)
+	}
+	if len(query) > 0 {
+		psEndpoint += "?" + query.Encode()
+	}
+
+	out := make([]*Process, 0)
+
+	if err := f.sendRequest(ctx, "ListProcesses", http.MethodGet, psEndpoint, nil, &out, nil); err != nil {
+		return nil, fmt.Errorf("failed to list processes on VM %s: %w", machineID, err)
+	}
+	return out, nil
+}
+
+func (f *Client) Get(ctx context.Context, machineID string, opts ...CallOption) (*Machine, error) {
 	getEndpoint := ""
 
 	if machineID != "" {
@@ -131,51 +426,134 @@ func (f *Client) Get(ctx context.Context, machineID string) (*Machine, error) {
 
 	out := new(Machine)
 
-	err := f.sendRequest(ctx, http.MethodGet, getEndpoint, nil, out, nil)
+	err := f.sendRequest(ctx, "Get", http.MethodGet, getEndpoint, nil, out, f.callHeaders(opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get VM %s: %w", machineID, err)
 	}
 	return out, nil
 }
 
-func (f *Client) List(ctx context.Context, state string) ([]*Machine, error) {
+func (f *Client) List(ctx context.Context, filter ListFilter, opts ...CallOption) ([]*Machine, error) {
+	if err := f.checkState(filter.State); err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	if err := filter.validate(); err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+
 	getEndpoint := ""
 
-	if state != "" {
-		getEndpoint = fmt.Sprintf("?%s", state)
+	if query := filter.values(); len(query) > 0 {
+		getEndpoint = "?" + query.Encode()
 	}
 
 	out := make([]*Machine, 0)
 
-	err := f.sendRequest(ctx, http.MethodGet, getEndpoint, nil, &out, nil)
+	err := f.sendRequest(ctx, "List", http.MethodGet, getEndpoint, nil, &out, f.callHeaders(opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list VMs: %w", err)
 	}
+	if out == nil {
+		out = make([]*Machine, 0)
+	}
+	return out, nil
+}
+
+func (f *Client) ListEvents(ctx context.Context, machineID string) ([]*MachineEvent, error) {
+	eventsEndpoint := fmt.Sprintf("/%s/events", machineID)
+
+	out := make([]*MachineEvent, 0)
+
+	err := f.sendRequest(ctx, "ListEvents", http.MethodGet, eventsEndpoint, nil, &out, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events for VM %s: %w", machineID, err)
+	}
 	return out, nil
 }
 
-func (f *Client) Destroy(ctx context.Context, input RemoveMachineInput) (err error) {
-	destroyEndpoint := fmt.Sprintf("/%s?kill=%t", input.ID, input.Kill)
+func (f *Client) ListVersions(ctx context.Context, machineID string) ([]*MachineVersion, error) {
+	versionsEndpoint := fmt.Sprintf("/%s/versions", machineID)
 
-	if err := f.sendRequest(ctx, http.MethodDelete, destroyEndpoint, nil, nil, nil); err != nil {
+	out := make([]*MachineVersion, 0)
+
+	err := f.sendRequest(ctx, "ListVersions", http.MethodGet, versionsEndpoint, nil, &out, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of VM %s: %w", machineID, err)
+	}
+	return out, nil
+}
+
+// Destroy deletes a machine. A leased machine is only destroyed when
+// input.Nonce (or WithNonce) carries the lease's nonce.
+func (f *Client) Destroy(ctx context.Context, input RemoveMachineInput, opts ...CallOption) (err error) {
+	query := url.Values{}
+	query.Set("kill", strconv.FormatBool(input.Kill))
+	if input.Force {
+		query.Set("force", "true")
+	}
+	destroyEndpoint := fmt.Sprintf("/%s?%s", input.ID, query.Encode())
+
+	headers := f.callHeaders(opts)
+	if input.Nonce != "" {
+		headers[f.nonceHeader] = []string{input.Nonce}
+	}
+
+	if err := f.sendRequest(ctx, "Destroy", http.MethodDelete, destroyEndpoint, nil, nil, headers); err != nil {
 		return fmt.Errorf("failed to destroy VM %s: %w", input.ID, err)
 	}
 
 	return
 }
 
-func (f *Client) Kill(ctx context.Context, machineID string) (err error) {
+func (f *Client) Kill(ctx context.Context, machineID string, opts ...CallOption) error {
+	return f.Signal(ctx, machineID, 9, opts...)
+}
+
+func (f *Client) Signal(ctx context.Context, machineID string, signal int, opts ...CallOption) (err error) {
 	in := map[string]interface{}{
-		"signal": 9,
+		"signal": signal,
 	}
-	err = f.sendRequest(ctx, http.MethodPost, fmt.Sprintf("/%s/signal", machineID), in, nil, nil)
+	err = f.sendRequest(ctx, "Signal", http.MethodPost, fmt.Sprintf("/%s/signal", machineID), in, nil, f.callHeaders(opts))
 
 	if err != nil {
-		return fmt.Errorf("failed to kill VM %s: %w", machineID, err)
+		return fmt.Errorf("failed to signal VM %s: %w", machineID, err)
 	}
 	return
 }
 
+var signalNumbers = map[string]int{
+	"SIGHUP":  1,
+	"SIGINT":  2,
+	"SIGQUIT": 3,
+	"SIGKILL": 9,
+	"SIGUSR1": 10,
+	"SIGUSR2": 12,
+	"SIGTERM": 15,
+}
+
+// signalName returns the name of the signal number n, e.g. "SIGTERM".
+func signalName(n int) (string, bool) {
+	for name, number := range signalNumbers {
+		if number == n {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// SignalByName returns the number of the named signal, e.g. "SIGTERM" or
+// "term".
+func SignalByName(name string) (int, error) {
+	key := strings.ToUpper(name)
+	if !strings.HasPrefix(key, "SIG") {
+		key = "SIG" + key
+	}
+	if n, ok := signalNumbers[key]; ok {
+		return n, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", name)
+}
+
 func (f *Client) GetLease(ctx context.Context, machineID string, ttl *int) (*MachineLease, error) {
 	endpoint := fmt.Sprintf("/%s/lease", machineID)
 
@@ -185,98 +563,313 @@ func (f *Client) GetLease(ctx context.Context, machineID string, ttl *int) (*Mac
 
 	out := new(MachineLease)
 
-	err := f.sendRequest(ctx, http.MethodPost, endpoint, nil, out, nil)
+	err := f.sendRequest(ctx, "GetLease", http.MethodPost, endpoint, nil, out, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get lease on VM %s: %w", machineID, err)
 	}
+	f.nonces.setLease(machineID, out)
 	return out, nil
 }
 
-func (f *Client) ReleaseLease(ctx context.Context, machineID, nonce string) error {
+// ViewLease returns the lease currently held on the machine without taking it.
+// An error satisfying IsNotFound is returned when there is none.
+func (f *Client) ViewLease(ctx context.Context, machineID string) (*MachineLease, error) {
 	endpoint := fmt.Sprintf("/%s/lease", machineID)
 
+	out := new(MachineLease)
+
+	err := f.sendRequest(ctx, "ViewLease", http.MethodGet, endpoint, nil, out, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to view lease on VM %s: %w", machineID, err)
+	}
+	return out, nil
+}
+
+// RenewLease extends the lease identified by nonce by ttl seconds. An error
+// satisfying IsConflict is returned when nonce is stale.
+func (f *Client) RenewLease(ctx context.Context, machineID, nonce string, ttl int) (*MachineLease, error) {
+	endpoint := fmt.Sprintf("/%s/lease?ttl=%d", machineID, ttl)
+
 	headers := make(map[string][]string)
 
 	if nonce != "" {
-		headers[NonceHeader] = []string{nonce}
+		headers[f.nonceHeader] = []string{nonce}
 	}
 
-	return f.sendRequest(ctx, http.MethodDelete, endpoint, nil, nil, headers)
-}
+	out := new(MachineLease)
 
-func (f *Client) sendRequest(ctx context.Context, method, endpoint string, in, out interface{}, headers map[string][]string) error {
-	req, err := f.NewRequest(ctx, method, endpoint, in, headers)
+	err := f.sendRequest(ctx, "RenewLease", http.MethodPost, endpoint, nil, out, headers)
 	if err != nil {
+		return nil, fmt.Errorf("failed to renew lease on VM %s: %w", machineID, err)
+	}
+	f.nonces.setLease(machineID, out)
+	return out, nil
+}
+
+func (f *Client) ReleaseLease(ctx context.Context, machineID, nonce string) error {
+	endpoint := fmt.Sprintf("/%s/lease", machineID)
+
+	headers := make(map[string][]string)
+
+	if nonce != "" {
+		headers[f.nonceHeader] = []string{nonce}
+	}
+
+	if err := f.sendRequest(ctx, "ReleaseLease", http.MethodDelete, endpoint, nil, nil, headers); err != nil {
 		return err
 	}
+	f.nonces.forget(machineID, nonce)
+	return nil
+}
+
+// sendRequest makes an API call on the app's machines. operation names the
+// call for instruments, after the Client method making it.
+func (f *Client) sendRequest(ctx context.Context, operation, method, endpoint string, in, out interface{}, headers map[string][]string) error {
+	return f.sendAppRequest(ctx, operation, method, f.machinesPath(endpoint), in, out, headers)
+}
+
+// sendAppRequest is like sendRequest, except that path is relative to the
+// API root rather than to the app's machines.
+func (f *Client) sendAppRequest(ctx context.Context, operation, method, path string, in, out interface{}, headers map[string][]string) (err error) {
+	if _, ok := ctx.Deadline(); !ok && f.defaultTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.defaultTimeout)
+		defer cancel()
+	}
+
+	headers, cachedNonce := f.withCachedNonce(method, path, headers)
+
+	var statusCode int
+	if len(f.instruments) > 0 {
+		var finish func(int, error)
+		ctx, finish = f.startCall(ctx, operation, method, path)
+		defer func() { finish(statusCode, err) }()
+	}
+
+	for attempt := 1; ; attempt++ {
+		if f.limiter != nil {
+			if err := f.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		req, err := f.newAppRequest(ctx, method, path, in, headers)
+		if err != nil {
+			return err
+		}
+
+		for _, middleware := range f.requestMiddleware {
+			if err := middleware(req); err != nil {
+				return err
+			}
+		}
+		if f.dryRun && method != http.MethodGet && method != http.MethodHead {
+			return &dryRunError{req: req}
+		}
+
+		resp, err := f.do(req)
+		if resp != nil {
+			statusCode = resp.StatusCode
+			f.rateLimit.observe(resp.Header)
+			f.observeNonce(path, resp, cachedNonce)
+			if err := f.runResponseMiddleware(resp); err != nil {
+				return err
+			}
+		}
+		if attempt < f.maxAttempts && f.shouldRetry(req, resp, err) {
+			delay := f.retryDelay(attempt)
+			if resp != nil {
+				if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+					delay = retryAfter
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		captureResponse(ctx, resp)
+		return f.handleResponse(resp, out)
+	}
+}
 
+func (f *Client) do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
 	resp, err := f.httpClient.Do(req)
+	f.logRequest(req, resp, err, time.Since(start))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := decompressBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
 	}
+	return resp, nil
+}
+
+func (f *Client) runResponseMiddleware(resp *http.Response) error {
+	for _, middleware := range f.responseMiddleware {
+		if err := middleware(resp); err != nil {
+			resp.Body.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Client) handleResponse(resp *http.Response, out interface{}) error {
 	defer resp.Body.Close()
 
+	maxBytes := f.maxResponseBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxResponseBytes
+	}
+
 	if resp.StatusCode > 299 {
-		return handleAPIError(resp)
+		return handleAPIError(resp, maxBytes)
 	}
 	if out != nil {
-		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > maxBytes {
+			return fmt.Errorf("%w of %d bytes", ErrResponseTooLarge, maxBytes)
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(body, out); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// Do calls an endpoint the client has no method for yet. path is relative to
+// the app's machines, e.g. "/<id>/ps", and body, when not nil, is sent as
+// JSON. The response is decoded into out, when not nil, and errors, retries
+// and headers work as for the typed methods.
+func (f *Client) Do(ctx context.Context, method, path string, body, out interface{}, opts ...CallOption) error {
+	return f.sendRequest(ctx, "Do", method, path, body, out, f.callHeaders(opts))
+}
+
 func (f *Client) NewRequest(ctx context.Context, method, path string, in interface{}, headers map[string][]string) (*http.Request, error) {
+	return f.newAppRequest(ctx, method, f.machinesPath(path), in, headers)
+}
+
+func (f *Client) machinesPath(path string) string {
+	return fmt.Sprintf("/apps/%s/machines%s", f.appName, path)
+}
+
+func (f *Client) newAppRequest(ctx context.Context, method, path string, in interface{}, headers map[string][]string) (*http.Request, error) {
 	var (
-		body io.Reader
-		host = f.host
+		body   io.Reader
+		length int64
 	)
 
-	if headers == nil {
-		headers = make(map[string][]string)
-	}
-
-	targetEndpoint := fmt.Sprintf("http://[%s]:4280/v1/apps/%s/machines%s", host, f.appName, path)
+	targetEndpoint := f.BaseURL() + path
 
 	if in != nil {
 		b, err := json.Marshal(in)
 		if err != nil {
 			return nil, err
 		}
-		headers["Content-Type"] = []string{"application/json"}
 
 		body = bytes.NewReader(b)
+		length = int64(len(b))
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, targetEndpoint, body)
 	if err != nil {
 		return nil, fmt.Errorf("could not create new request, %w", err)
 	}
-	req.Header = headers
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", f.authToken))
+	// headers are merged in, but can't override the headers the client
+	// manages. The body's length is always known, so it is never sent
+	// chunked, and GetBody is set for redirects.
+	for key, values := range headers {
+		switch http.CanonicalHeaderKey(key) {
+		case "Authorization", "Content-Length", "Transfer-Encoding":
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.ContentLength = length
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	token, err := f.tokenSource(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get auth token, %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("User-Agent", f.userAgent())
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 
 	return req, nil
 }
 
-func handleAPIError(resp *http.Response) error {
+// BaseURL returns the URL requests are built against. Unless overridden with
+// WithBaseURL, it points at the client's host: the internal Flaps port over
+// http, or the default port over https.
+func (f *Client) BaseURL() string {
+	if f.baseURL != "" {
+		return f.baseURL
+	}
+
+	scheme := f.scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	host := strings.TrimSuffix(strings.TrimPrefix(f.host, "["), "]")
+	if scheme == "http" {
+		host = net.JoinHostPort(host, "4280")
+	} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+
+	return fmt.Sprintf("%s://%s/v1", scheme, host)
+}
+
+// handleAPIError builds a FlapsError from resp, reading at most maxBytes of
+// its body.
+func handleAPIError(resp *http.Response, maxBytes int64) error {
+	flapsErr := &FlapsError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(requestIDHeader),
+	}
+	flapsErr.RetryAfter, _ = parseRetryAfter(resp.Header.Get("Retry-After"))
+
+	// The body is read once, up front, so that whatever arrived is kept for
+	// the error message even if reading fails partway or it isn't JSON.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	flapsErr.ResponseBody = body
+	if err != nil {
+		return flapsErr
+	}
+
 	switch resp.StatusCode / 100 {
-	case 1, 3:
-		return fmt.Errorf("API returned unexpected status, %d", resp.StatusCode)
 	case 4, 5:
 		apiErr := struct {
 			Error   string `json:"error"`
 			Message string `json:"message,omitempty"`
 		}{}
-		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
-			return fmt.Errorf("request returned non-2xx status, %d", resp.StatusCode)
-		}
-		if apiErr.Message != "" {
-			return fmt.Errorf("%s", apiErr.Message)
+		if err := json.Unmarshal(body, &apiErr); err == nil {
+			flapsErr.APIError = apiErr.Error
+			flapsErr.Message = apiErr.Message
 		}
-		return errors.New(apiErr.Error)
-	default:
-		return errors.New("something went terribly wrong")
 	}
+	return flapsErr
 }
@@ -0,0 +1,371 @@
+package flaps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a client for the app "test-app" pointed at a server
+// running handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(append([]Option{
+		WithBaseURL(server.URL + "/v1"),
+		WithToken("test-token"),
+		WithApp("test-app"),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"ipv6 internal host", []Option{WithHost("fdaa:0:1::3")}, "http://[fdaa:0:1::3]:4280/v1"},
+		{"bracketed ipv6 host", []Option{WithHost("[fdaa:0:1::3]")}, "http://[fdaa:0:1::3]:4280/v1"},
+		{"dns hostname over https", []Option{WithHost("api.machines.dev"), WithScheme("https")}, "https://api.machines.dev/v1"},
+		{"ipv6 host over https", []Option{WithHost("fdaa:0:1::3"), WithScheme("https")}, "https://[fdaa:0:1::3]/v1"},
+		{"base url", []Option{WithBaseURL("http://127.0.0.1:8080/v1/")}, "http://127.0.0.1:8080/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(append(tt.opts, WithToken("test-token"))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := client.BaseURL(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCordonUncordon(t *testing.T) {
+	var got []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+	})
+
+	if err := client.Cordon(context.Background(), "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Uncordon(context.Background(), "m1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"POST /v1/apps/test-app/machines/m1/cordon",
+		"POST /v1/apps/test-app/machines/m1/uncordon",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got requests %q, want %q", got, want)
+	}
+}
+
+func TestDeleteApp(t *testing.T) {
+	var got string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Method + " " + r.URL.Path
+	})
+
+	if err := client.DeleteApp(context.Background(), "other-app"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "DELETE /v1/apps/other-app"; got != want {
+		t.Fatalf("got request %q, want %q", got, want)
+	}
+}
+
+func TestStopWithResponse(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1/apps/test-app/machines/m1/stop"; r.Method != http.MethodPost || r.URL.Path != want {
+			t.Errorf("got request %s %s, want POST %s", r.Method, r.URL.Path, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"stopped","previous_state":"started"}`))
+	})
+
+	resp, err := client.StopWithResponse(context.Background(), StopMachineInput{ID: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (MachineStopResponse{Status: "stopped", PreviousState: "started"}); *resp != want {
+		t.Fatalf("got response %+v, want %+v", *resp, want)
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     RemoveMachineInput
+		wantQuery string
+		wantNonce string
+	}{
+		{"plain", RemoveMachineInput{ID: "m1"}, "kill=false", ""},
+		{"kill with nonce", RemoveMachineInput{ID: "m1", Kill: true, Nonce: "n1"}, "kill=true", "n1"},
+		{"force", RemoveMachineInput{ID: "m1", Force: true}, "force=true&kill=false", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if want := "/v1/apps/test-app/machines/m1"; r.Method != http.MethodDelete || r.URL.Path != want {
+					t.Errorf("got request %s %s, want DELETE %s", r.Method, r.URL.Path, want)
+				}
+				if r.URL.RawQuery != tt.wantQuery {
+					t.Errorf("got query %q, want %q", r.URL.RawQuery, tt.wantQuery)
+				}
+				if got := r.Header.Get(NonceHeader); got != tt.wantNonce {
+					t.Errorf("got nonce header %q, want %q", got, tt.wantNonce)
+				}
+			})
+
+			if err := client.Destroy(context.Background(), tt.input); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewRequestContentLength(t *testing.T) {
+	var gotLength int64
+	var gotEncoding []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotLength, gotEncoding = r.ContentLength, r.TransferEncoding
+	})
+
+	in := LaunchMachineInput{Config: &MachineConfig{Image: "nginx"}}
+	want, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := client.NewRequest(context.Background(), http.MethodPost, "", in, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != int64(len(want)) {
+		t.Fatalf("got ContentLength %d, want %d", req.ContentLength, len(want))
+	}
+	if req.GetBody == nil {
+		t.Fatal("GetBody is not set")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(body); string(b) != string(want) {
+		t.Fatalf("GetBody returned %s, want %s", b, want)
+	}
+
+	if _, err := client.Launch(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if gotLength != int64(len(want)) || len(gotEncoding) > 0 {
+		t.Fatalf("server got Content-Length %d and Transfer-Encoding %q, want %d and none", gotLength, gotEncoding, len(want))
+	}
+}
+
+func TestNewRequestHeaders(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	headers := map[string][]string{
+		"authorization": {"Bearer stray"},
+		"X-Custom":      {"a", "b"},
+	}
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "/m1", nil, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Header.Values("Authorization"), []string{"Bearer test-token"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got Authorization %q, want %q", got, want)
+	}
+	if got, want := req.Header.Values("X-Custom"), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got X-Custom %q, want %q", got, want)
+	}
+	if req.Header.Get("User-Agent") == "" || req.Header.Get("Accept-Encoding") != "gzip" {
+		t.Errorf("default headers are missing: %v", req.Header)
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("fly-force-instance-id"); got != "i1" {
+			t.Errorf("got fly-force-instance-id %q, want i1", got)
+		}
+		if got, want := r.Header.Values("Authorization"), []string{"Bearer test-token"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got Authorization %q, want %q", got, want)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("got Content-Type %q, want application/json", got)
+		}
+		w.Write([]byte(`{"exit_code":0}`))
+	})
+
+	_, err := client.Exec(context.Background(), "m1", ExecRequest{Cmd: "true"},
+		WithHeader("fly-force-instance-id", "i1"),
+		WithHeader("Authorization", "evil"),
+		WithHeader("Content-Type", "text/plain"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListApps(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages map[string]string
+		want  []string
+	}{
+		{
+			"two pages",
+			map[string]string{
+				"":   `{"apps":[{"name":"a1"},{"name":"a2"}],"next_cursor":"c1"}`,
+				"c1": `{"apps":[{"name":"a3"}]}`,
+			},
+			[]string{"a1", "a2", "a3"},
+		},
+		{
+			"repeated cursor",
+			map[string]string{
+				"":   `{"apps":[{"name":"a1"}],"next_cursor":"c1"}`,
+				"c1": `{"apps":[{"name":"a2"}],"next_cursor":"c1"}`,
+			},
+			[]string{"a1", "a2"},
+		},
+		{"no apps", map[string]string{"": `{"apps":[]}`}, []string{}},
+		{"no apps key", map[string]string{"": `{}`}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.URL.Path != "/v1/apps" || r.URL.Query().Get("org_slug") != "test-org" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				page, ok := tt.pages[r.URL.Query().Get("cursor")]
+				if !ok || requests > len(tt.pages) {
+					t.Errorf("unexpected request %d for %s", requests, r.URL)
+					page = `{"apps":[]}`
+				}
+				w.Write([]byte(page))
+			}, WithOrg("test-org"))
+
+			apps, err := client.ListApps(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if apps == nil {
+				t.Fatal("got nil apps, want an empty slice")
+			}
+			names := []string{}
+			for _, app := range apps {
+				names = append(names, app.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("got apps %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestListAppsRequiresOrg(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+	if _, err := client.ListApps(context.Background()); err == nil {
+		t.Fatal("ListApps without an organization succeeded")
+	}
+}
+
+func TestUpdateWithOptions(t *testing.T) {
+	var updates []LaunchMachineInput
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/test-app/machines/m1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Method == http.MethodPost {
+			var in LaunchMachineInput
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				t.Error(err)
+			}
+			updates = append(updates, in)
+		}
+		w.Write([]byte(`{"id":"m1","instance_id":"i2"}`))
+	})
+	ctx := context.Background()
+	input := LaunchMachineInput{ID: "m1", Config: &MachineConfig{Image: "nginx:2"}}
+
+	_, err := client.UpdateWithOptions(ctx, input, "", UpdateOptions{ExpectedVersion: "i1"})
+	if !errors.Is(err, ErrVersionMismatch) || !strings.Contains(err.Error(), "expected i1, found i2") {
+		t.Fatalf("got error %v, want a version mismatch", err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("the update was sent despite the version mismatch")
+	}
+
+	machine, err := client.UpdateWithOptions(ctx, input, "", UpdateOptions{ExpectedVersion: "i2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if machine.ID != "m1" || len(updates) != 1 || updates[0].Config.Image != "nginx:2" {
+		t.Fatalf("got machine %+v and updates %+v, want nginx:2 sent to m1", machine, updates)
+	}
+}
+
+func TestDo(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/apps/test-app/machines/m1/ps":
+			if r.Method != http.MethodGet || r.URL.Query().Get("sort_by") != "cpu" {
+				t.Errorf("got request %s %s, want GET with sort_by=cpu", r.Method, r.URL)
+			}
+			w.Write([]byte(`[{"pid":1,"command":"/init"}]`))
+		case "/v1/apps/test-app/machines/m1/exec":
+			var in ExecRequest
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Cmd != "true" {
+				t.Errorf("got body %+v (%v), want cmd true", in, err)
+			}
+			if r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("got Content-Type %q, want application/json", r.Header.Get("Content-Type"))
+			}
+			w.Write([]byte(`{"exit_code":0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+		}
+	})
+	ctx := context.Background()
+
+	var processes []map[string]interface{}
+	if err := client.Do(ctx, http.MethodGet, "/m1/ps?sort_by=cpu", nil, &processes); err != nil {
+		t.Fatal(err)
+	}
+	if len(processes) != 1 || processes[0]["command"] != "/init" {
+		t.Fatalf("got processes %v", processes)
+	}
+
+	if err := client.Do(ctx, http.MethodPost, "/m1/exec", ExecRequest{Cmd: "true"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.Do(ctx, http.MethodGet, "/m1/unknown", nil, nil)
+	var flapsErr *FlapsError
+	if !errors.As(err, &flapsErr) || !IsNotFound(err) || flapsErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("got error %v, want a FlapsError with status 404", err)
+	}
+}
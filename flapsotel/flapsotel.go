@@ -0,0 +1,87 @@
+// Package flapsotel instruments flaps clients with OpenTelemetry. It lives in
+// its own module so that the flaps package doesn't depend on OpenTelemetry.
+package flapsotel
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/mikefrey/flaps"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/mikefrey/flaps/flapsotel"
+
+// WithTracerProvider records a client span, named after the operation (e.g.
+// "flaps.Launch"), for every API call the client makes.
+func WithTracerProvider(tp trace.TracerProvider) flaps.Option {
+	tracer := tp.Tracer(instrumentationName)
+
+	return flaps.WithInstrument(func(ctx context.Context, call flaps.CallInfo) (context.Context, func(int, error)) {
+		attrs := []attribute.KeyValue{
+			attribute.String("flaps.app_name", call.AppName),
+			attribute.String("http.request.method", call.Method),
+		}
+		if call.MachineID != "" {
+			attrs = append(attrs, attribute.String("flaps.machine_id", call.MachineID))
+		}
+
+		ctx, span := tracer.Start(ctx, "flaps."+call.Operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+
+		return ctx, func(statusCode int, err error) {
+			if statusCode != 0 {
+				span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+			}
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+	})
+}
+
+// WithMeterProvider records the duration of every API call the client makes
+// in the flaps.client.request.duration histogram, tagged by operation and
+// outcome. It panics if the histogram can't be created.
+func WithMeterProvider(mp metric.MeterProvider) flaps.Option {
+	histogram, err := mp.Meter(instrumentationName).Float64Histogram(
+		"flaps.client.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of Flaps API calls."),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	return flaps.WithInstrument(func(ctx context.Context, call flaps.CallInfo) (context.Context, func(int, error)) {
+		start := time.Now()
+
+		return ctx, func(statusCode int, err error) {
+			histogram.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+				attribute.String("operation", call.Operation),
+				attribute.String("outcome", outcome(statusCode, err)),
+			))
+		}
+	})
+}
+
+func outcome(statusCode int, err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case statusCode >= http.StatusInternalServerError:
+		return "server_error"
+	case statusCode >= http.StatusBadRequest:
+		return "client_error"
+	default:
+		return "error"
+	}
+}
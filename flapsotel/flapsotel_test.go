@@ -0,0 +1,150 @@
+package flapsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWithTracerProvider(t *testing.T) {
+	server, _ := flapstest.NewServer()
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	client, err := server.NewClient(flapstest.AppName,
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(ctx, "missing"); err == nil {
+		t.Fatal("got no error for a missing machine")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	launch := spans[0]
+	if launch.Name() != "flaps.Launch" || launch.SpanKind() != trace.SpanKindClient {
+		t.Errorf("got span %q of kind %v, want a client span flaps.Launch", launch.Name(), launch.SpanKind())
+	}
+	assertAttributes(t, launch.Attributes(), map[attribute.Key]attribute.Value{
+		"flaps.app_name":            attribute.StringValue(flapstest.AppName),
+		"http.request.method":       attribute.StringValue("POST"),
+		"http.response.status_code": attribute.IntValue(200),
+	})
+	if launch.Status().Code != codes.Unset {
+		t.Errorf("got status %v for a successful call, want unset", launch.Status())
+	}
+
+	get := spans[1]
+	if get.Name() != "flaps.Get" {
+		t.Errorf("got span %q, want flaps.Get", get.Name())
+	}
+	assertAttributes(t, get.Attributes(), map[attribute.Key]attribute.Value{
+		"flaps.machine_id":          attribute.StringValue("missing"),
+		"http.response.status_code": attribute.IntValue(404),
+	})
+	if get.Status().Code != codes.Error || len(get.Events()) == 0 {
+		t.Errorf("got status %v and %d events for a failed call, want an error and its event", get.Status(), len(get.Events()))
+	}
+}
+
+func TestWithMeterProvider(t *testing.T) {
+	server, _ := flapstest.NewServer()
+	defer server.Close()
+
+	reader := sdkmetric.NewManualReader()
+	client, err := server.NewClient(flapstest.AppName,
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(ctx, "missing"); err == nil {
+		t.Fatal("got no error for a missing machine")
+	}
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &data); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[[2]string]uint64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != "flaps.client.request.duration" {
+				continue
+			}
+			histogram, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok {
+				t.Fatalf("got %T, want a float64 histogram", m.Data)
+			}
+			for _, point := range histogram.DataPoints {
+				operation, _ := point.Attributes.Value("operation")
+				outcome, _ := point.Attributes.Value("outcome")
+				counts[[2]string{operation.AsString(), outcome.AsString()}] += point.Count
+			}
+		}
+	}
+
+	want := map[[2]string]uint64{
+		{"Launch", "success"}:   1,
+		{"Get", "client_error"}: 1,
+	}
+	if len(counts) != len(want) {
+		t.Errorf("got data points %v, want %v", counts, want)
+	}
+	for labels, n := range want {
+		if counts[labels] != n {
+			t.Errorf("got %d calls with operation=%q, outcome=%q, want %d", counts[labels], labels[0], labels[1], n)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		err        error
+		want       string
+	}{
+		{200, nil, "success"},
+		{404, context.Canceled, "client_error"},
+		{503, context.Canceled, "server_error"},
+		{200, context.Canceled, "error"},
+		{0, context.Canceled, "error"},
+	}
+	for _, tt := range tests {
+		if got := outcome(tt.statusCode, tt.err); got != tt.want {
+			t.Errorf("outcome(%d, %v) = %q, want %q", tt.statusCode, tt.err, got, tt.want)
+		}
+	}
+}
+
+func assertAttributes(t *testing.T, got []attribute.KeyValue, want map[attribute.Key]attribute.Value) {
+	t.Helper()
+	set := attribute.NewSet(got...)
+	for key, value := range want {
+		if v, ok := set.Value(key); !ok || v != value {
+			t.Errorf("got attribute %s = %v, want %v", key, v.Emit(), value.Emit())
+		}
+	}
+}
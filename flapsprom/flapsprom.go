@@ -0,0 +1,76 @@
+// Package flapsprom exports Prometheus metrics for flaps clients. It lives in
+// its own module so that the flaps package doesn't depend on Prometheus.
+package flapsprom
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mikefrey/flaps"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithMetricsRegistry registers the client's metrics with reg and updates
+// them on every API call:
+//
+//   - flaps_client_requests_total, counting calls by operation and status
+//     class ("4xx", "5xx", ..., or "error" when no response came back or a
+//     2xx response couldn't be used, e.g. because it failed to decode)
+//   - flaps_client_request_duration_seconds, a histogram of call durations
+//     by operation, with buckets reaching the 60s a Wait request can take
+//
+// Labels don't include app or machine names, to keep cardinality bounded.
+// Clients registering with the same reg share the metrics. It panics if the
+// metrics can't be registered.
+func WithMetricsRegistry(reg prometheus.Registerer) flaps.Option {
+	requests := register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flaps_client_requests_total",
+		Help: "Flaps API calls by operation and status class.",
+	}, []string{"operation", "status_class"}))
+
+	durations := register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flaps_client_request_duration_seconds",
+		Help:    "Duration of Flaps API calls.",
+		Buckets: durationBuckets,
+	}, []string{"operation"}))
+
+	return flaps.WithInstrument(func(ctx context.Context, call flaps.CallInfo) (context.Context, func(int, error)) {
+		start := time.Now()
+
+		return ctx, func(statusCode int, err error) {
+			durations.WithLabelValues(call.Operation).Observe(time.Since(start).Seconds())
+			requests.WithLabelValues(call.Operation, statusClass(statusCode, err)).Inc()
+		}
+	})
+}
+
+// register registers c with reg, or returns the equivalent collector already
+// registered.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if err := reg.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// durationBuckets extend prometheus.DefBuckets, which stop at 10s, past the
+// longest calls the server holds open.
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+func statusClass(statusCode int, err error) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "error"
+	}
+	if err != nil && statusCode < 300 {
+		return "error"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
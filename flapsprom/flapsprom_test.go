@@ -0,0 +1,82 @@
+package flapsprom
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRequestsByStatusClass(t *testing.T) {
+	server, _ := flapstest.NewServer()
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	failDecode := false
+	client, err := server.NewClient(flapstest.AppName,
+		WithMetricsRegistry(reg),
+		flaps.WithResponseMiddleware(func(resp *http.Response) error {
+			if failDecode {
+				return errors.New("unusable response")
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	machine, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(ctx, "missing"); err == nil {
+		t.Fatal("got no error for a missing machine")
+	}
+	failDecode = true
+	if _, err := client.Get(ctx, machine.ID); err == nil {
+		t.Fatal("got no error from the failing middleware")
+	}
+
+	for _, tt := range []struct {
+		operation, class string
+		want             float64
+	}{
+		{"Launch", "2xx", 1},
+		{"Get", "4xx", 1},
+		{"Get", "error", 1},
+		{"Get", "2xx", 0},
+	} {
+		if got := requestCount(t, reg, tt.operation, tt.class); got != tt.want {
+			t.Errorf("requests{operation=%q, status_class=%q} = %v, want %v", tt.operation, tt.class, got, tt.want)
+		}
+	}
+}
+
+func requestCount(t *testing.T, reg *prometheus.Registry, operation, class string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "flaps_client_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["operation"] == operation && labels["status_class"] == class {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
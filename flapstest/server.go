@@ -0,0 +1,626 @@
+// Package flapstest provides an in-memory fake of the Flaps API for testing
+// code that uses a flaps.Client.
+//
+// The fake models the machine lifecycle: launching stores a started machine
+// (a created one with SkipLaunch), start, stop, suspend, restart and destroy
+// move it between states, wait blocks until the machine reaches the requested
+// state, and list reflects the stored machines. Leases are enforced on
+// mutating calls, and metadata and events are kept per machine. State changes
+// take effect immediately.
+package flapstest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikefrey/flaps"
+)
+
+// AppName is the app the Client returned by NewServer manages.
+const AppName = "flapstest-app"
+
+const nonceHeader = "fly-machine-lease-nonce"
+
+// Server is a fake Flaps API served over HTTP.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	machines map[string]*machine
+	// changed is closed and replaced whenever a machine changes state, waking
+	// up pending waits.
+	changed chan struct{}
+}
+
+type machine struct {
+	flaps.Machine
+
+	app      string
+	seq      int
+	cordoned bool
+	lease    *flaps.MachineLease
+}
+
+// NewServer starts a fake Flaps server and returns it along with a Client
+// for AppName pointed at it. Call Close on the server when done.
+func NewServer() (*Server, *flaps.Client) {
+	s := &Server{
+		machines: make(map[string]*machine),
+		changed:  make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	prefix := "/v1/apps/{app}/machines"
+	mux.HandleFunc("GET "+prefix, s.list)
+	mux.HandleFunc("POST "+prefix, s.launch)
+	mux.HandleFunc("GET "+prefix+"/{id}", s.get)
+	mux.HandleFunc("POST "+prefix+"/{id}", s.update)
+	mux.HandleFunc("DELETE "+prefix+"/{id}", s.destroy)
+	mux.HandleFunc("POST "+prefix+"/{id}/{action}", s.action)
+	mux.HandleFunc("GET "+prefix+"/{id}/wait", s.wait)
+	mux.HandleFunc("GET "+prefix+"/{id}/events", s.events)
+	mux.HandleFunc("GET "+prefix+"/{id}/lease", s.viewLease)
+	mux.HandleFunc("POST "+prefix+"/{id}/lease", s.acquireLease)
+	mux.HandleFunc("DELETE "+prefix+"/{id}/lease", s.releaseLease)
+	mux.HandleFunc("GET "+prefix+"/{id}/metadata", s.getMetadata)
+	mux.HandleFunc("POST "+prefix+"/{id}/metadata/{key}", s.setMetadata)
+	mux.HandleFunc("DELETE "+prefix+"/{id}/metadata/{key}", s.deleteMetadata)
+
+	s.Server = httptest.NewServer(authenticated(mux))
+
+	client, err := s.NewClient(AppName)
+	if err != nil {
+		panic(err)
+	}
+	return s, client
+}
+
+// NewClient returns a Client for app pointed at the server.
+func (s *Server) NewClient(app string, opts ...flaps.Option) (*flaps.Client, error) {
+	return flaps.NewClient(append([]flaps.Option{
+		flaps.WithBaseURL(s.URL + "/v1"),
+		flaps.WithToken("flapstest"),
+		flaps.WithApp(app),
+		flaps.WithHTTPClient(s.Server.Client()),
+	}, opts...)...)
+}
+
+// Machine returns a copy of the machine stored under id, including destroyed
+// ones, for making assertions.
+func (s *Server) Machine(id string) (*flaps.Machine, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.machines {
+		if m.ID == id {
+			return m.snapshot(), true
+		}
+	}
+	return nil, false
+}
+
+// Cordoned reports whether the machine stored under id is cordoned.
+func (s *Server) Cordoned(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.machines {
+		if m.ID == id {
+			return m.cordoned
+		}
+	}
+	return false
+}
+
+func authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	includeDeleted, _ := strconv.ParseBool(query.Get("include_deleted"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := make([]*machine, 0)
+	for _, m := range s.machines {
+		if m.app != r.PathValue("app") {
+			continue
+		}
+		if m.State == "destroyed" && !includeDeleted {
+			continue
+		}
+		if state := query.Get("state"); state != "" && m.State != state {
+			continue
+		}
+		if region := query.Get("region"); region != "" && m.Region != region {
+			continue
+		}
+		if !matchesMetadata(m, query) {
+			continue
+		}
+		matched = append(matched, m)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].seq < matched[j].seq })
+
+	out := make([]*flaps.Machine, 0, len(matched))
+	for _, m := range matched {
+		out = append(out, m.snapshot())
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func matchesMetadata(m *machine, query map[string][]string) bool {
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "metadata.")
+		if !ok {
+			continue
+		}
+		if m.Config == nil || m.Config.Metadata[name] != values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) launch(w http.ResponseWriter, r *http.Request) {
+	var in flaps.LaunchMachineInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if in.Config == nil {
+		writeError(w, http.StatusBadRequest, "config is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := in.ID
+	if id == "" {
+		id = newID(7)
+	}
+	if _, ok := s.machines[key(r.PathValue("app"), id)]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("machine %s already exists", id))
+		return
+	}
+
+	name := in.Name
+	if name == "" {
+		name = "machine-" + id
+	}
+	region := in.Region
+	if region == "" {
+		region = "iad"
+	}
+
+	now := timestamp()
+	m := &machine{
+		Machine: flaps.Machine{
+			ID:         id,
+			Name:       name,
+			State:      "created",
+			Region:     region,
+			InstanceID: newInstanceID(),
+			PrivateIP:  fmt.Sprintf("fdaa:0:1:a7b:1::%d", len(s.machines)+1),
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			Config:     in.Config,
+		},
+		app: r.PathValue("app"),
+		seq: len(s.machines),
+	}
+	m.ImageRef.Repository = in.Config.Image
+	s.machines[key(m.app, id)] = m
+
+	m.addEvent("launch", "created", "user")
+	if !in.SkipLaunch {
+		s.transition(m, "started", "start")
+	}
+
+	writeJSON(w, http.StatusOK, m.snapshot())
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, m.snapshot())
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	var in flaps.LaunchMachineInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if in.Config == nil {
+		writeError(w, http.StatusBadRequest, "config is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookupMutable(w, r)
+	if !ok {
+		return
+	}
+
+	m.Config = in.Config
+	m.ImageRef.Repository = in.Config.Image
+	m.InstanceID = newInstanceID()
+	if in.Name != "" {
+		m.Name = in.Name
+	}
+	m.addEvent("update", "replaced", "user")
+	s.transition(m, "started", "start")
+
+	writeJSON(w, http.StatusOK, m.snapshot())
+}
+
+func (s *Server) destroy(w http.ResponseWriter, r *http.Request) {
+	kill, _ := strconv.ParseBool(r.URL.Query().Get("kill"))
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookupMutable(w, r)
+	if !ok {
+		return
+	}
+	if m.State == "started" && !kill && !force {
+		writeError(w, http.StatusPreconditionFailed, "failed_precondition: unable to destroy machine, not currently stopped")
+		return
+	}
+
+	m.lease = nil
+	s.transition(m, "destroyed", "destroy")
+
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) action(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookupMutable(w, r)
+	if !ok {
+		return
+	}
+
+	previous := m.State
+
+	switch action := r.PathValue("action"); action {
+	case "start":
+		if previous != "started" {
+			s.transition(m, "started", "start")
+		}
+		writeJSON(w, http.StatusOK, flaps.MachineStartResponse{Status: "started", PreviousState: previous})
+	case "stop":
+		if previous != "stopped" {
+			s.transition(m, "stopped", "stop")
+		}
+		writeJSON(w, http.StatusOK, flaps.MachineStopResponse{Status: "stopped", PreviousState: previous})
+	case "suspend":
+		if previous != "started" {
+			writeError(w, http.StatusPreconditionFailed, fmt.Sprintf("unable to suspend machine from current state: '%s'", previous))
+			return
+		}
+		s.transition(m, "suspended", "suspend")
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	case "restart":
+		s.transition(m, "started", "restart")
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	case "signal":
+		if previous != "started" {
+			writeError(w, http.StatusPreconditionFailed, fmt.Sprintf("unable to signal machine in state: '%s'", previous))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	case "cordon", "uncordon":
+		m.cordoned = action == "cordon"
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) wait(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	state := query.Get("state")
+	if state == "" {
+		state = "started"
+	}
+	timeout := 60 * time.Second
+	if seconds, err := strconv.Atoi(query.Get("timeout")); err == nil && seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		m, ok := s.lookup(w, r)
+		if !ok {
+			s.mu.Unlock()
+			return
+		}
+		if m.State == state {
+			s.mu.Unlock()
+			writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+			return
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			writeError(w, http.StatusRequestTimeout, fmt.Sprintf("deadline_exceeded: machine did not reach %s state", state))
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, m.snapshot().Events)
+}
+
+func (s *Server) viewLease(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if !m.leased() {
+		writeError(w, http.StatusNotFound, "lease not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, m.lease)
+}
+
+func (s *Server) acquireLease(w http.ResponseWriter, r *http.Request) {
+	ttl := 30
+	if seconds, err := strconv.Atoi(r.URL.Query().Get("ttl")); err == nil && seconds > 0 {
+		ttl = seconds
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	nonce := r.Header.Get(nonceHeader)
+	switch {
+	case m.leased() && nonce != m.lease.Data.Nonce:
+		writeError(w, http.StatusConflict, fmt.Sprintf("machine ID %s lease currently held by %s", m.ID, m.lease.Data.Owner))
+		return
+	case !m.leased():
+		m.lease = &flaps.MachineLease{Status: "success"}
+		m.lease.Data.Nonce = newID(6)
+		m.lease.Data.Owner = "flapstest@fly.io"
+	}
+	m.lease.Data.ExpiresAt = time.Now().Add(time.Duration(ttl) * time.Second).Unix()
+
+	writeJSON(w, http.StatusOK, m.lease)
+}
+
+func (s *Server) releaseLease(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookupMutable(w, r)
+	if !ok {
+		return
+	}
+	m.lease = nil
+
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) getMetadata(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	out := make(map[string]string)
+	if m.Config != nil {
+		for k, v := range m.Config.Metadata {
+			out[k] = v
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) setMetadata(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookupMutable(w, r)
+	if !ok {
+		return
+	}
+	if m.Config == nil {
+		m.Config = &flaps.MachineConfig{}
+	}
+	if m.Config.Metadata == nil {
+		m.Config.Metadata = make(map[string]string)
+	}
+	m.Config.Metadata[r.PathValue("key")] = in.Value
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteMetadata(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.lookupMutable(w, r)
+	if !ok {
+		return
+	}
+	if m.Config != nil {
+		delete(m.Config.Metadata, r.PathValue("key"))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup finds the machine the request addresses, writing a 404 if there is
+// none. s.mu must be held.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (*machine, bool) {
+	m, ok := s.machines[key(r.PathValue("app"), r.PathValue("id"))]
+	if !ok {
+		writeError(w, http.StatusNotFound, "machine not found")
+		return nil, false
+	}
+	return m, true
+}
+
+// lookupMutable is like lookup, but also writes a 409 if the machine is
+// leased and the request doesn't carry the lease's nonce, and a 404 if the
+// machine is destroyed. s.mu must be held.
+func (s *Server) lookupMutable(w http.ResponseWriter, r *http.Request) (*machine, bool) {
+	m, ok := s.lookup(w, r)
+	if !ok {
+		return nil, false
+	}
+	if m.State == "destroyed" {
+		writeError(w, http.StatusNotFound, "machine not found")
+		return nil, false
+	}
+	if m.leased() && r.Header.Get(nonceHeader) != m.lease.Data.Nonce {
+		writeError(w, http.StatusConflict, fmt.Sprintf("machine ID %s lease currently held by %s", m.ID, m.lease.Data.Owner))
+		return nil, false
+	}
+	return m, true
+}
+
+// transition moves m to state, records it as an event of the given type and
+// wakes up pending waits. s.mu must be held.
+func (s *Server) transition(m *machine, state, eventType string) {
+	m.State = state
+	m.UpdatedAt = timestamp()
+	m.addEvent(eventType, state, "user")
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (m *machine) leased() bool {
+	return m.lease != nil && time.Now().Unix() < m.lease.Data.ExpiresAt
+}
+
+func (m *machine) addEvent(eventType, status, source string) {
+	m.Events = append([]*flaps.MachineEvent{{
+		ID:        newID(8),
+		Type:      eventType,
+		Status:    status,
+		Source:    source,
+		Timestamp: time.Now().UnixMilli(),
+	}}, m.Events...)
+}
+
+// snapshot returns a deep enough copy of m to hand out without racing with
+// later changes.
+func (m *machine) snapshot() *flaps.Machine {
+	out := m.Machine
+	if m.Config != nil {
+		config := *m.Config
+		if m.Config.Metadata != nil {
+			config.Metadata = make(map[string]string, len(m.Config.Metadata))
+			for k, v := range m.Config.Metadata {
+				config.Metadata[k] = v
+			}
+		}
+		out.Config = &config
+	}
+	out.Events = make([]*flaps.MachineEvent, len(m.Events))
+	for i, event := range m.Events {
+		e := *event
+		out.Events[i] = &e
+	}
+	return &out
+}
+
+func key(app, id string) string {
+	return app + "/" + id
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func newInstanceID() string {
+	return strings.ToUpper(newID(13))
+}
+
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,139 @@
+package flapstest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+func launch(t *testing.T, client *flaps.Client, metadata map[string]string) *flaps.Machine {
+	t.Helper()
+	machine, err := client.Launch(context.Background(), flaps.LaunchMachineInput{
+		Config: &flaps.MachineConfig{Image: "nginx", Metadata: metadata},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return machine
+}
+
+func TestListMatchesAllMetadata(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+
+	both := launch(t, client, map[string]string{"role": "worker", "tier": "gold"})
+	launch(t, client, map[string]string{"role": "worker", "tier": "silver"})
+	launch(t, client, map[string]string{"tier": "gold"})
+
+	machines, _, err := client.ListPage(context.Background(), flaps.ListFilter{
+		Metadata: map[string]string{"role": "worker", "tier": "gold"},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 1 || machines[0].ID != both.ID {
+		t.Fatalf("got %d machines, want only %s", len(machines), both.ID)
+	}
+}
+
+func TestSuspendStartRoundTrip(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	machine := launch(t, client, nil)
+
+	if err := client.Suspend(ctx, machine.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := server.Machine(machine.ID); got.State != "suspended" {
+		t.Fatalf("got state %q after Suspend, want suspended", got.State)
+	}
+
+	resp, err := client.Start(ctx, machine.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.PreviousState != "suspended" {
+		t.Errorf("got previous state %q, want suspended", resp.PreviousState)
+	}
+	if got, _ := server.Machine(machine.ID); got.State != "started" {
+		t.Fatalf("got state %q after Start, want started", got.State)
+	}
+}
+
+func TestSuspendRequiresStartedMachine(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	machine := launch(t, client, nil)
+
+	if err := client.Stop(ctx, flaps.StopMachineInput{ID: machine.ID}); err != nil {
+		t.Fatal(err)
+	}
+	err := client.Suspend(ctx, machine.ID)
+	var flapsErr *flaps.FlapsError
+	if !errors.As(err, &flapsErr) || flapsErr.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("got error %v, want a 412", err)
+	}
+}
+
+func TestDestroyStartedMachine(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	machine := launch(t, client, nil)
+
+	if err := client.Destroy(ctx, flaps.RemoveMachineInput{ID: machine.ID}); err == nil {
+		t.Fatal("destroying a started machine without kill succeeded")
+	}
+	if err := client.Destroy(ctx, flaps.RemoveMachineInput{ID: machine.ID, Kill: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := server.Machine(machine.ID); got.State != "destroyed" {
+		t.Fatalf("got state %q, want destroyed", got.State)
+	}
+
+	machines, _, err := client.ListPage(ctx, flaps.ListFilter{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 0 {
+		t.Errorf("listed %d machines, want destroyed ones left out", len(machines))
+	}
+	machines, _, err = client.ListPage(ctx, flaps.ListFilter{IncludeDeleted: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 1 {
+		t.Errorf("listed %d machines with IncludeDeleted, want 1", len(machines))
+	}
+}
+
+func TestLeaseBlocksOtherClients(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	machine := launch(t, client, nil)
+
+	other, err := server.NewClient(flapstest.AppName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := 60
+	if _, err := other.GetLease(ctx, machine.ID, &ttl); err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Stop(ctx, flaps.StopMachineInput{ID: machine.ID})
+	var flapsErr *flaps.FlapsError
+	if !errors.As(err, &flapsErr) || flapsErr.StatusCode != http.StatusConflict {
+		t.Fatalf("got error %v, want a 409", err)
+	}
+	if err := other.Stop(ctx, flaps.StopMachineInput{ID: machine.ID}); err != nil {
+		t.Fatalf("the lease holder could not stop the machine: %v", err)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/mikefrey/flaps
 
-go 1.18
+go 1.24.0
@@ -0,0 +1,82 @@
+package flaps
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImageRef is a parsed container image reference.
+type ImageRef struct {
+	// Registry is the registry host, with its port if any. It is
+	// "docker.io" for references that don't name one.
+	Registry string
+	// Repository is the image path within the registry. Docker Hub images
+	// named without a namespace are in "library/".
+	Repository string
+	// Tag is "latest" when the reference names neither a tag nor a digest.
+	Tag    string
+	Digest string
+}
+
+// ParseImageRef parses an image reference such as "nginx",
+// "registry.fly.io/my-app:deployment-01" or "localhost:5000/app@sha256:...",
+// filling in the implicit registry and tag.
+func ParseImageRef(s string) (ImageRef, error) {
+	var ref ImageRef
+	if s == "" {
+		return ref, fmt.Errorf("invalid image reference %q: empty", s)
+	}
+
+	name := s
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		algorithm, hex, ok := strings.Cut(ref.Digest, ":")
+		if !ok || algorithm == "" || hex == "" {
+			return ImageRef{}, fmt.Errorf("invalid image reference %q: malformed digest", s)
+		}
+	}
+
+	// A colon after the last slash starts the tag; one before it belongs
+	// to the registry's port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		if ref.Tag == "" {
+			return ImageRef{}, fmt.Errorf("invalid image reference %q: empty tag", s)
+		}
+	}
+
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, ref.Repository = first, rest
+	} else {
+		ref.Registry, ref.Repository = "docker.io", name
+	}
+	if ref.Repository == "" || strings.HasPrefix(ref.Repository, "/") || strings.HasSuffix(ref.Repository, "/") {
+		return ImageRef{}, fmt.Errorf("invalid image reference %q: malformed repository", s)
+	}
+	if ref.Registry == "docker.io" && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// String returns the fully qualified form of the reference.
+func (r ImageRef) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// ImageRef parses the config's Image.
+func (c *MachineConfig) ImageRef() (ImageRef, error) {
+	return ParseImageRef(c.Image)
+}
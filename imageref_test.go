@@ -0,0 +1,51 @@
+package flaps
+
+import "testing"
+
+func TestParseImageRef(t *testing.T) {
+	const digest = "sha256:0123456789abcdef"
+
+	tests := []struct {
+		in   string
+		want ImageRef
+	}{
+		{"nginx", ImageRef{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"}},
+		{"nginx:1.25", ImageRef{Registry: "docker.io", Repository: "library/nginx", Tag: "1.25"}},
+		{"grafana/grafana", ImageRef{Registry: "docker.io", Repository: "grafana/grafana", Tag: "latest"}},
+		{"nginx@" + digest, ImageRef{Registry: "docker.io", Repository: "library/nginx", Digest: digest}},
+		{"nginx:1.25@" + digest, ImageRef{Registry: "docker.io", Repository: "library/nginx", Tag: "1.25", Digest: digest}},
+		{"registry.fly.io/my-app:deployment-01", ImageRef{Registry: "registry.fly.io", Repository: "my-app", Tag: "deployment-01"}},
+		{"localhost:5000/app", ImageRef{Registry: "localhost:5000", Repository: "app", Tag: "latest"}},
+		{"localhost:5000/team/app:v2@" + digest, ImageRef{Registry: "localhost:5000", Repository: "team/app", Tag: "v2", Digest: digest}},
+		{"localhost/app", ImageRef{Registry: "localhost", Repository: "app", Tag: "latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseImageRef(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImageRefInvalid(t *testing.T) {
+	for _, in := range []string{"", "nginx:", "nginx@sha256", "nginx@:abc", "registry.fly.io/", "docker.io//nginx"} {
+		if ref, err := ParseImageRef(in); err == nil {
+			t.Errorf("ParseImageRef(%q) = %+v, want an error", in, ref)
+		}
+	}
+}
+
+func TestImageRefString(t *testing.T) {
+	ref, err := ParseImageRef("nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ref.String(), "docker.io/library/nginx:latest"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
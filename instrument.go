@@ -0,0 +1,62 @@
+package flaps
+
+import (
+	"context"
+	"strings"
+)
+
+// CallInfo describes an API call to an Instrument.
+type CallInfo struct {
+	// Operation names the call after the Client method making the request,
+	// e.g. "Launch". Methods such as RollingUpdate make several calls, each
+	// named after the method it goes through, e.g. "Get" or "Update".
+	Operation string
+	AppName   string
+	// MachineID is empty for calls that aren't scoped to a single machine.
+	MachineID string
+	Method    string
+	Path      string
+}
+
+// Instrument observes API calls, e.g. to trace them or record metrics. It is
+// invoked as each call starts and may return a derived context, which the
+// call then runs under. The returned func is invoked once the call completes,
+// including any retries, with the status code of the last response (0 if
+// none was received) and the call's error.
+type Instrument func(ctx context.Context, call CallInfo) (context.Context, func(statusCode int, err error))
+
+func (f *Client) startCall(ctx context.Context, operation, method, path string) (context.Context, func(int, error)) {
+	call := CallInfo{
+		Operation: operation,
+		AppName:   f.appName,
+		MachineID: machineIDFromPath(f.machinesPath(""), path),
+		Method:    method,
+		Path:      path,
+	}
+
+	finishers := make([]func(int, error), 0, len(f.instruments))
+	for _, instrument := range f.instruments {
+		var finish func(int, error)
+		ctx, finish = instrument(ctx, call)
+		finishers = append(finishers, finish)
+	}
+
+	return ctx, func(statusCode int, err error) {
+		for i := len(finishers) - 1; i >= 0; i-- {
+			if finishers[i] != nil {
+				finishers[i](statusCode, err)
+			}
+		}
+	}
+}
+
+func machineIDFromPath(prefix, path string) string {
+	if !strings.HasPrefix(path, prefix+"/") {
+		return ""
+	}
+	id := path[len(prefix)+1:]
+	if i := strings.IndexAny(id, "/?"); i >= 0 {
+		id = id[:i]
+	}
+	return id
+}
@@ -0,0 +1,58 @@
+package flaps_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+func TestInstrumentOperations(t *testing.T) {
+	server, _ := flapstest.NewServer()
+	defer server.Close()
+
+	var (
+		mu         sync.Mutex
+		operations []string
+	)
+	client, err := server.NewClient(flapstest.AppName, flaps.WithInstrument(func(ctx context.Context, call flaps.CallInfo) (context.Context, func(int, error)) {
+		mu.Lock()
+		operations = append(operations, call.Operation)
+		mu.Unlock()
+		return ctx, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	machine, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Stop(ctx, flaps.StopMachineInput{ID: machine.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RollingUpdate(ctx, flaps.LaunchMachineInput{
+		ID:     machine.ID,
+		Config: &flaps.MachineConfig{Image: "nginx:1.27"},
+	}, flaps.RollingUpdateOptions{CheckInterval: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Launch",
+		"Stop",
+		// RollingUpdate
+		"GetLease", "Get", "Update", "Wait", "Get", "ReleaseLease",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(operations, want) {
+		t.Fatalf("got operations %v, want %v", operations, want)
+	}
+}
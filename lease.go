@@ -0,0 +1,146 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Lease is a machine lease kept alive in the background by HoldLease.
+type Lease struct {
+	MachineID string
+
+	client *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	errs   chan error
+
+	mu    sync.Mutex
+	lease *MachineLease
+
+	releaseOnce sync.Once
+	releaseErr  error
+}
+
+// HoldLease acquires a lease on the machine and renews it every ttl/2 until
+// Release is called or ctx is done. When ctx is done renewal stops and the
+// lease is left to expire.
+func (f *Client) HoldLease(ctx context.Context, machineID string, ttl time.Duration) (*Lease, error) {
+	seconds := int(ttl / time.Second)
+	if seconds < 1 {
+		return nil, fmt.Errorf("failed to hold lease on VM %s: ttl must be at least 1s, got %s", machineID, ttl)
+	}
+
+	machineLease, err := f.GetLease(ctx, machineID, &seconds)
+	if err != nil {
+		return nil, err
+	}
+
+	renewCtx, cancel := context.WithCancel(ctx)
+	l := &Lease{
+		MachineID: machineID,
+		client:    f,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		errs:      make(chan error, 1),
+		lease:     machineLease,
+	}
+	f.leases.add(l)
+	go l.renew(renewCtx, seconds, ttl/2)
+
+	return l, nil
+}
+
+// Nonce returns the nonce of the lease, to pass to calls made under it.
+func (l *Lease) Nonce() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lease.Data.Nonce
+}
+
+// Errors delivers renewal failures, which mean the lease may be lost. It is
+// closed once renewal stops. Failures that occur while an earlier one is
+// still unread are dropped.
+func (l *Lease) Errors() <-chan error {
+	return l.errs
+}
+
+// Release stops renewing the lease and releases it.
+func (l *Lease) Release() error {
+	l.releaseOnce.Do(func() {
+		l.cancel()
+		<-l.done
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(l.ctx), 10*time.Second)
+		defer cancel()
+		l.releaseErr = l.client.ReleaseLease(ctx, l.MachineID, l.Nonce())
+	})
+	return l.releaseErr
+}
+
+func (l *Lease) renew(ctx context.Context, ttl int, interval time.Duration) {
+	defer close(l.done)
+	defer close(l.errs)
+	defer l.client.leases.remove(l)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		machineLease, err := l.client.RenewLease(ctx, l.MachineID, l.Nonce(), ttl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case l.errs <- err:
+			default:
+			}
+			continue
+		}
+
+		l.mu.Lock()
+		l.lease = machineLease
+		l.mu.Unlock()
+	}
+}
+
+// leaseSet tracks the leases a client is renewing, for Close.
+type leaseSet struct {
+	mu     sync.Mutex
+	leases map[*Lease]struct{}
+}
+
+func (s *leaseSet) add(l *Lease) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases == nil {
+		s.leases = make(map[*Lease]struct{})
+	}
+	s.leases[l] = struct{}{}
+}
+
+func (s *leaseSet) remove(l *Lease) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.leases, l)
+}
+
+func (s *leaseSet) all() []*Lease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	leases := make([]*Lease, 0, len(s.leases))
+	for l := range s.leases {
+		leases = append(leases, l)
+	}
+	return leases
+}
@@ -0,0 +1,144 @@
+package flaps_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+func launchMachine(t *testing.T, client *flaps.Client) *flaps.Machine {
+	t.Helper()
+	machine, err := client.Launch(context.Background(), flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return machine
+}
+
+func TestHoldLeaseRenews(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	machine := launchMachine(t, client)
+
+	// The fake keeps expiry times in whole seconds, so the TTL leaves
+	// room for the first renewal, due after 1.5s, to land in time.
+	lease, err := client.HoldLease(ctx, machine.ID, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial, err := client.ViewLease(ctx, machine.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current, err := client.ViewLease(ctx, machine.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Data.Nonce != lease.Nonce() {
+			t.Fatalf("got nonce %q, want %q", current.Data.Nonce, lease.Nonce())
+		}
+		if current.Data.ExpiresAt > initial.Data.ExpiresAt {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lease still expires at %d, want it renewed", current.Data.ExpiresAt)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := lease.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-lease.Errors(); ok {
+		t.Error("Errors() is still open after Release")
+	}
+	if _, err := client.ViewLease(ctx, machine.ID); !flaps.IsNotFound(err) {
+		t.Fatalf("got error %v viewing a released lease, want not found", err)
+	}
+}
+
+func TestReleaseAfterCancel(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	machine := launchMachine(t, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lease, err := client.HoldLease(ctx, machine.ID, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if err := lease.Release(); err != nil {
+		t.Fatalf("Release after cancel: %v", err)
+	}
+	if _, err := client.ViewLease(context.Background(), machine.ID); !flaps.IsNotFound(err) {
+		t.Fatalf("got error %v viewing a released lease, want not found", err)
+	}
+}
+
+func TestHoldLeaseReportsRenewalFailures(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	machine := launchMachine(t, client)
+
+	lease, err := client.HoldLease(ctx, machine.ID, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Release()
+
+	// Another client takes the lease over, so renewing the old nonce fails.
+	other, err := server.NewClient(flapstest.AppName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.ReleaseLease(ctx, machine.ID, lease.Nonce()); err != nil {
+		t.Fatal(err)
+	}
+	ttl := 60
+	if _, err := other.GetLease(ctx, machine.ID, &ttl); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-lease.Errors():
+		if !flaps.IsConflict(err) {
+			t.Fatalf("got renewal error %v, want a conflict", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no renewal failure was reported")
+	}
+}
+
+func TestViewLease(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	machine := launchMachine(t, client)
+
+	if _, err := client.ViewLease(ctx, machine.ID); !flaps.IsNotFound(err) {
+		t.Fatalf("got error %v for a machine without a lease, want not found", err)
+	}
+
+	ttl := 60
+	acquired, err := client.GetLease(ctx, machine.ID, &ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewed, err := client.ViewLease(ctx, machine.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if viewed.Data.Nonce != acquired.Data.Nonce || viewed.Data.Owner == "" || viewed.Data.ExpiresAt != acquired.Data.ExpiresAt {
+		t.Fatalf("got lease %+v, want %+v", viewed.Data, acquired.Data)
+	}
+}
@@ -0,0 +1,113 @@
+package flaps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+)
+
+// ListPage returns one page of the machines matching filter, starting at
+// pageToken ("" for the first page), and the token of the next page, which
+// is "" after the last one. Against a server that doesn't paginate, the first
+// page holds every machine.
+func (f *Client) ListPage(ctx context.Context, filter ListFilter, pageToken string) ([]*Machine, string, error) {
+	if err := f.checkState(filter.State); err != nil {
+		return nil, "", fmt.Errorf("failed to list VMs: %w", err)
+	}
+	if err := filter.validate(); err != nil {
+		return nil, "", fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	query := filter.values()
+	if filter.PageSize > 0 {
+		query.Set("limit", strconv.Itoa(filter.PageSize))
+	}
+	if pageToken != "" {
+		query.Set("cursor", pageToken)
+	}
+
+	getEndpoint := ""
+	if len(query) > 0 {
+		getEndpoint = "?" + query.Encode()
+	}
+
+	var raw json.RawMessage
+
+	if err := f.sendRequest(ctx, "ListPage", http.MethodGet, getEndpoint, nil, &raw, nil); err != nil {
+		return nil, "", fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	page := struct {
+		Machines   []*Machine `json:"machines"`
+		NextCursor string     `json:"next_cursor"`
+	}{}
+
+	var err error
+	if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '[' {
+		err = json.Unmarshal(raw, &page.Machines)
+	} else {
+		err = json.Unmarshal(raw, &page)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	if page.Machines == nil {
+		page.Machines = make([]*Machine, 0)
+	}
+	return page.Machines, page.NextCursor, nil
+}
+
+// ListAll iterates over the machines matching filter, fetching them a page
+// at a time. A failed page is yielded as an error, which ends the iteration.
+func (f *Client) ListAll(ctx context.Context, filter ListFilter) iter.Seq2[*Machine, error] {
+	return func(yield func(*Machine, error) bool) {
+		pageToken := ""
+		for {
+			machines, next, err := f.ListPage(ctx, filter, pageToken)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, machine := range machines {
+				if !yield(machine, nil) {
+					return
+				}
+			}
+			// A server handing back the cursor it was sent would
+			// otherwise have this loop forever.
+			if next == "" || next == pageToken {
+				return
+			}
+			pageToken = next
+		}
+	}
+}
+
+// GetMachineByName returns the machine called name. Flaps can't filter by
+// name, so every machine of the app is listed. An error wrapping
+// ErrMachineNotFound is returned when none matches, and an error when several
+// do, since names aren't guaranteed to be unique.
+func (f *Client) GetMachineByName(ctx context.Context, name string) (*Machine, error) {
+	var found *Machine
+	for machine, err := range f.ListAll(ctx, ListFilter{}) {
+		if err != nil {
+			return nil, err
+		}
+		if machine.Name != name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("several machines are named %q: %s and %s", name, found.ID, machine.ID)
+		}
+		found = machine
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no machine named %q: %w", name, ErrMachineNotFound)
+	}
+	return found, nil
+}
@@ -0,0 +1,145 @@
+package flaps
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestListStateQuery(t *testing.T) {
+	var got string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RawQuery
+		w.Write([]byte("[]"))
+	})
+
+	if _, _, err := client.ListPage(context.Background(), ListFilter{State: StateStopped}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := "state=stopped"; got != want {
+		t.Fatalf("got query %q, want %q", got, want)
+	}
+}
+
+func TestListCombinedFilters(t *testing.T) {
+	var got url.Values
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		w.Write([]byte("[]"))
+	})
+
+	filter := ListFilter{
+		State:          StateStarted,
+		Region:         "iad",
+		IncludeDeleted: true,
+		Metadata:       map[string]string{MetadataProcessGroup: "web"},
+	}
+	if _, _, err := client.ListPage(context.Background(), filter, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{
+		"state":                            {"started"},
+		"region":                           {"iad"},
+		"include_deleted":                  {"true"},
+		"metadata." + MetadataProcessGroup: {"web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got query %v, want %v", got, want)
+	}
+}
+
+func TestListMetadataFilter(t *testing.T) {
+	var got url.Values
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		w.Write([]byte("[]"))
+	})
+
+	filter := ListFilter{Metadata: map[string]string{"role": "worker", "tier": "gold"}}
+	if _, _, err := client.ListPage(context.Background(), filter, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{"metadata.role": {"worker"}, "metadata.tier": {"gold"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got query %v, want %v", got, want)
+	}
+}
+
+func TestListRejectsEmptyMetadata(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+
+	for _, metadata := range []map[string]string{{"": "worker"}, {"role": ""}} {
+		if _, _, err := client.ListPage(context.Background(), ListFilter{Metadata: metadata}, ""); err == nil {
+			t.Errorf("ListPage with metadata filter %q succeeded, want an error", metadata)
+		}
+	}
+}
+
+func TestListAllStopsOnRepeatedCursor(t *testing.T) {
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 3 {
+			t.Errorf("listing didn't stop after %d requests", requests)
+			w.Write([]byte(`{"machines":[]}`))
+			return
+		}
+		if r.URL.Query().Get("cursor") == "" {
+			w.Write([]byte(`{"machines":[{"id":"m1"}],"next_cursor":"c1"}`))
+			return
+		}
+		w.Write([]byte(`{"machines":[{"id":"m2"}],"next_cursor":"c1"}`))
+	})
+
+	var ids []string
+	for machine, err := range client.ListAll(context.Background(), ListFilter{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, machine.ID)
+	}
+	if want := []string{"m1", "m2"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got machines %v, want %v", ids, want)
+	}
+}
+
+func TestList(t *testing.T) {
+	for _, body := range []string{"[]", "null", ""} {
+		t.Run(body, func(t *testing.T) {
+			var got string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.RawQuery
+				w.Write([]byte(body))
+			})
+
+			machines, err := client.List(context.Background(), ListFilter{State: StateSuspended})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if machines == nil || len(machines) != 0 {
+				t.Fatalf("got machines %v, want an empty, non-nil slice", machines)
+			}
+			if want := "state=suspended"; got != want {
+				t.Fatalf("got query %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestListMachines(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"m1","state":"started"},{"id":"m2","state":"started"}]`))
+	})
+
+	machines, err := client.List(context.Background(), ListFilter{State: StateStarted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 2 || machines[0].ID != "m1" || machines[1].ID != "m2" {
+		t.Fatalf("got machines %+v, want m1 and m2", machines)
+	}
+}
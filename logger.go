@@ -0,0 +1,31 @@
+package flaps
+
+import (
+	"net/http"
+	"time"
+)
+
+// Logger receives debug traces of the requests a Client makes.
+type Logger interface {
+	Debugf(format string, args ...any)
+}
+
+func (f *Client) logRequest(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	if f.logger == nil {
+		return
+	}
+	if err != nil {
+		f.logger.Debugf("flaps: %s %s %v failed after %s: %v", req.Method, req.URL, redactHeaders(req.Header), elapsed, err)
+		return
+	}
+	f.logger.Debugf("flaps: %s %s %v -> %d in %s", req.Method, req.URL, redactHeaders(req.Header), resp.StatusCode, elapsed)
+}
+
+// redactHeaders returns a copy of h that is safe to log.
+func redactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	if redacted.Get("Authorization") != "" {
+		redacted.Set("Authorization", "[REDACTED]")
+	}
+	return redacted
+}
@@ -0,0 +1,148 @@
+package flaps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// LogEntry is a line of a machine's log output.
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Instance  string    `json:"instance"`
+	Region    string    `json:"region"`
+
+	// Err is set on the entry TailLogs sends last when it gives up on the
+	// stream, which carries nothing else.
+	Err error `json:"-"`
+}
+
+// logPollInterval is how often TailLogs polls for new lines. Tests shorten it.
+var logPollInterval = time.Second
+
+// GetRecentLogs returns up to the last n lines logged by the machine.
+//
+// Logs aren't served by Flaps itself but by the Fly API, so the client's
+// base URL must point there, e.g. "https://api.fly.io/api/v1".
+func (f *Client) GetRecentLogs(ctx context.Context, machineID string, n int) ([]LogEntry, error) {
+	entries, _, err := f.fetchLogs(ctx, "GetRecentLogs", machineID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs of VM %s: %w", machineID, err)
+	}
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries, nil
+}
+
+// TailLogs streams the machine's log lines until ctx is done, at which point
+// the channel is closed. Failures after the first request that may be
+// transient, such as network errors, 429s and 5xx responses, are retried with
+// backoff. Any other failure, e.g. a revoked token, ends the stream: the
+// channel is closed after an entry whose Err is set.
+//
+// Like GetRecentLogs, it needs a base URL pointing at the Fly API.
+func (f *Client) TailLogs(ctx context.Context, machineID string) (<-chan LogEntry, error) {
+	entries, nextToken, err := f.fetchLogs(ctx, "TailLogs", machineID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to tail logs of VM %s: %w", machineID, err)
+	}
+
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		failures := 0
+		for {
+			for _, entry := range entries {
+				select {
+				case out <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			delay := logPollInterval
+			if failures > 0 {
+				delay = backoff(logPollInterval, failures)
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				return
+			}
+
+			var token string
+			entries, token, err = f.fetchLogs(ctx, "TailLogs", machineID, nextToken)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if !transientLogError(err) {
+					select {
+					case out <- LogEntry{Err: fmt.Errorf("failed to tail logs of VM %s: %w", machineID, err)}:
+					case <-ctx.Done():
+					}
+					return
+				}
+				entries = nil
+				failures++
+				continue
+			}
+			failures = 0
+			if token != "" {
+				nextToken = token
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (f *Client) fetchLogs(ctx context.Context, operation, machineID, nextToken string) ([]LogEntry, string, error) {
+	query := url.Values{}
+	query.Set("instance", machineID)
+	if nextToken != "" {
+		query.Set("next_token", nextToken)
+	}
+
+	endpoint := fmt.Sprintf("/apps/%s/logs?%s", f.appName, query.Encode())
+
+	out := struct {
+		Data []struct {
+			Attributes LogEntry `json:"attributes"`
+		} `json:"data"`
+		Meta struct {
+			NextToken string `json:"next_token"`
+		} `json:"meta"`
+	}{}
+
+	if err := f.sendAppRequest(ctx, operation, http.MethodGet, endpoint, nil, &out, nil); err != nil {
+		return nil, "", err
+	}
+
+	entries := make([]LogEntry, 0, len(out.Data))
+	for _, data := range out.Data {
+		entries = append(entries, data.Attributes)
+	}
+	return entries, out.Meta.NextToken, nil
+}
+
+// transientLogError reports whether a failure to fetch logs may go away on
+// retrying: network errors, timeouts, rate limiting and server errors, but
+// not other 4xx responses or bodies that aren't logs.
+func transientLogError(err error) bool {
+	if status := StatusCode(err); status != 0 {
+		return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+	}
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	return !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) && !errors.Is(err, ErrResponseTooLarge)
+}
@@ -0,0 +1,95 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTailLogsGivesUpOnPermanentErrors(t *testing.T) {
+	defer func(interval time.Duration) { logPollInterval = interval }(logPollInterval)
+	logPollInterval = time.Millisecond
+
+	var requests atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			fmt.Fprint(w, `{"data":[{"attributes":{"message":"booting","instance":"m1"}}],"meta":{"next_token":"t1"}}`)
+		case 2:
+			// Transient, so retried.
+			w.WriteHeader(http.StatusBadGateway)
+		case 3:
+			if got := r.URL.Query().Get("next_token"); got != "t1" {
+				t.Errorf("next_token = %q, want t1", got)
+			}
+			fmt.Fprint(w, `{"data":[{"attributes":{"message":"ready","instance":"m1"}}],"meta":{"next_token":"t2"}}`)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"token revoked"}`)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entries, err := client.TailLogs(ctx, "m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	var last LogEntry
+	for entry := range entries {
+		if entry.Err == nil {
+			messages = append(messages, entry.Message)
+		}
+		last = entry
+	}
+	if ctx.Err() != nil {
+		t.Fatal("stream didn't end before ctx did")
+	}
+	if fmt.Sprint(messages) != "[booting ready]" {
+		t.Errorf("got messages %v, want [booting ready]", messages)
+	}
+	if !IsUnauthorized(last.Err) {
+		t.Fatalf("last entry has Err %v, want a 401", last.Err)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("got %d requests, want 4", got)
+	}
+}
+
+func TestTailLogsRetriesTransientErrors(t *testing.T) {
+	defer func(interval time.Duration) { logPollInterval = interval }(logPollInterval)
+	logPollInterval = time.Millisecond
+
+	// Only the first request, which TailLogs makes before streaming,
+	// succeeds.
+	var requests atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			fmt.Fprint(w, `{"data":[]}`)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, err := client.TailLogs(ctx, "m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	for entry := range entries {
+		if entry.Err != nil {
+			t.Fatalf("transient failures ended the stream: %v", entry.Err)
+		}
+	}
+	if requests.Load() < 2 {
+		t.Fatal("failed requests weren't retried")
+	}
+}
@@ -0,0 +1,113 @@
+package flaps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func (f *Client) GetMetadata(ctx context.Context, machineID string, opts ...CallOption) (map[string]string, error) {
+	metadataEndpoint := fmt.Sprintf("/%s/metadata", machineID)
+
+	out := make(map[string]string)
+
+	if err := f.sendRequest(ctx, "GetMetadata", http.MethodGet, metadataEndpoint, nil, &out, f.callHeaders(opts)); err != nil {
+		return nil, fmt.Errorf("failed to get metadata of VM %s: %w", machineID, err)
+	}
+	return out, nil
+}
+
+func (f *Client) SetMetadata(ctx context.Context, machineID, key, value string, opts ...CallOption) error {
+	metadataEndpoint := fmt.Sprintf("/%s/metadata/%s", machineID, url.PathEscape(key))
+
+	in := map[string]interface{}{
+		"value": value,
+	}
+
+	if err := f.sendRequest(ctx, "SetMetadata", http.MethodPost, metadataEndpoint, in, nil, f.callHeaders(opts)); err != nil {
+		return fmt.Errorf("failed to set metadata %s on VM %s: %w", key, machineID, err)
+	}
+	return nil
+}
+
+func (f *Client) DeleteMetadata(ctx context.Context, machineID, key string, opts ...CallOption) error {
+	metadataEndpoint := fmt.Sprintf("/%s/metadata/%s", machineID, url.PathEscape(key))
+
+	if err := f.sendRequest(ctx, "DeleteMetadata", http.MethodDelete, metadataEndpoint, nil, nil, f.callHeaders(opts)); err != nil {
+		return fmt.Errorf("failed to delete metadata %s on VM %s: %w", key, machineID, err)
+	}
+	return nil
+}
+
+// ReplaceMetadata makes md the machine's entire metadata. Flaps has no bulk
+// metadata endpoint, so this diffs md against GetMetadata and issues the
+// minimal set of per-key sets and deletes. If one of them fails, those
+// already applied are reverted on a best-effort basis before returning, even
+// once ctx is done; the error also reports any change that couldn't be
+// reverted, in which case the metadata is left partly replaced.
+func (f *Client) ReplaceMetadata(ctx context.Context, machineID string, md map[string]string, opts ...CallOption) error {
+	current, err := f.GetMetadata(ctx, machineID, opts...)
+	if err != nil {
+		return err
+	}
+
+	var undo []func(context.Context) error
+
+	apply := func(change func() error, revert func(context.Context) error) error {
+		err := change()
+		if err == nil {
+			undo = append(undo, revert)
+			return nil
+		}
+
+		// The failure may be ctx ending, so the changes are reverted under
+		// a budget of their own.
+		revertCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+
+		errs := []error{err}
+		for i := len(undo) - 1; i >= 0; i-- {
+			if revertErr := undo[i](revertCtx); revertErr != nil {
+				errs = append(errs, fmt.Errorf("failed to revert metadata of VM %s: %w", machineID, revertErr))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for key, value := range md {
+		old, existed := current[key]
+		if existed && old == value {
+			continue
+		}
+		err := apply(
+			func() error { return f.SetMetadata(ctx, machineID, key, value, opts...) },
+			func(ctx context.Context) error {
+				if existed {
+					return f.SetMetadata(ctx, machineID, key, old, opts...)
+				}
+				return f.DeleteMetadata(ctx, machineID, key, opts...)
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, old := range current {
+		if _, keep := md[key]; keep {
+			continue
+		}
+		err := apply(
+			func() error { return f.DeleteMetadata(ctx, machineID, key, opts...) },
+			func(ctx context.Context) error { return f.SetMetadata(ctx, machineID, key, old, opts...) },
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
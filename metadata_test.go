@@ -0,0 +1,94 @@
+package flaps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// metadataServer serves the metadata endpoints of a single machine from
+// memory. fail, when set, is consulted before each change and makes it fail
+// with a 500 when it returns true.
+type metadataServer struct {
+	mu   sync.Mutex
+	md   map[string]string
+	fail func(r *http.Request, key string) bool
+}
+
+func (s *metadataServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if r.Method != http.MethodGet && s.fail != nil && s.fail(r, key) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(s.md)
+	case http.MethodPost:
+		var in struct {
+			Value string `json:"value"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		s.md[key] = in.Value
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		delete(s.md, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestReplaceMetadataRevertsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := &metadataServer{
+		md: map[string]string{"a": "old", "c": "x"},
+		// Deleting c, which comes after setting a, fails as ctx ends.
+		fail: func(r *http.Request, key string) bool {
+			if r.Method == http.MethodDelete && key == "c" {
+				cancel()
+				return true
+			}
+			return false
+		},
+	}
+	client := newTestClient(t, server.ServeHTTP)
+
+	err := client.ReplaceMetadata(ctx, "m1", map[string]string{"a": "new"})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if strings.Contains(err.Error(), "failed to revert") {
+		t.Fatalf("revert failed: %v", err)
+	}
+	if server.md["a"] != "old" || server.md["c"] != "x" || len(server.md) != 2 {
+		t.Fatalf("metadata not reverted: %v", server.md)
+	}
+}
+
+func TestReplaceMetadataReportsFailedRevert(t *testing.T) {
+	server := &metadataServer{md: map[string]string{"a": "old", "c": "x"}}
+	server.fail = func(r *http.Request, key string) bool {
+		// Setting a succeeds once; deleting c and reverting a fail.
+		if r.Method == http.MethodPost && key == "a" {
+			return server.md["a"] == "new"
+		}
+		return r.Method == http.MethodDelete
+	}
+	client := newTestClient(t, server.ServeHTTP)
+
+	err := client.ReplaceMetadata(context.Background(), "m1", map[string]string{"a": "new"})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if !strings.Contains(err.Error(), "failed to delete metadata c") || !strings.Contains(err.Error(), "failed to revert metadata of VM m1") {
+		t.Fatalf("error doesn't report both failures: %v", err)
+	}
+}
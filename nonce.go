@@ -0,0 +1,127 @@
+package flaps
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedNonce returns the lease nonce the client last saw for the machine,
+// from a lease it acquired or a response header, if it is still current.
+// Mutating calls on the machine send it automatically unless given a nonce
+// of their own. It is dropped once the lease is released, expires or is
+// rejected.
+func (f *Client) CachedNonce(machineID string) (string, bool) {
+	return f.nonces.get(machineID)
+}
+
+type nonceCache struct {
+	mu      sync.Mutex
+	entries map[string]nonceEntry
+}
+
+type nonceEntry struct {
+	nonce string
+	// expires is zero for nonces seen in response headers, whose expiry
+	// isn't known.
+	expires time.Time
+}
+
+func (c *nonceCache) get(machineID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[machineID]
+	if !ok {
+		return "", false
+	}
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		delete(c.entries, machineID)
+		return "", false
+	}
+	return entry.nonce, true
+}
+
+func (c *nonceCache) set(machineID, nonce string, expires time.Time) {
+	if machineID == "" || nonce == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]nonceEntry)
+	}
+	c.entries[machineID] = nonceEntry{nonce: nonce, expires: expires}
+}
+
+// forget drops the cached nonce of the machine, if it is still nonce.
+func (c *nonceCache) forget(machineID, nonce string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[machineID]; ok && entry.nonce == nonce {
+		delete(c.entries, machineID)
+	}
+}
+
+func (c *nonceCache) setLease(machineID string, lease *MachineLease) {
+	var expires time.Time
+	if lease.Data.ExpiresAt > 0 {
+		expires = time.Unix(lease.Data.ExpiresAt, 0)
+	}
+	c.set(machineID, lease.Data.Nonce, expires)
+}
+
+// withCachedNonce returns headers with the cached nonce of the machine path
+// refers to added, for mutating requests that don't carry one already. Lease
+// requests manage their nonce themselves and are left alone. The nonce added,
+// if any, is returned too.
+func (f *Client) withCachedNonce(method, path string, headers map[string][]string) (map[string][]string, string) {
+	if method == http.MethodGet || method == http.MethodHead {
+		return headers, ""
+	}
+	machineID := machineIDFromPath(f.machinesPath(""), path)
+	if machineID == "" || strings.HasSuffix(path, "/lease") || strings.Contains(path, "/lease?") {
+		return headers, ""
+	}
+	if hasHeader(headers, f.nonceHeader) {
+		return headers, ""
+	}
+	nonce, ok := f.nonces.get(machineID)
+	if !ok {
+		return headers, ""
+	}
+
+	withNonce := make(map[string][]string, len(headers)+1)
+	for key, values := range headers {
+		withNonce[key] = values
+	}
+	withNonce[f.nonceHeader] = []string{nonce}
+	return withNonce, nonce
+}
+
+// observeNonce updates the cache from the response to a request on the
+// machine path refers to. sent is the cached nonce the request carried, if
+// any.
+func (f *Client) observeNonce(path string, resp *http.Response, sent string) {
+	machineID := machineIDFromPath(f.machinesPath(""), path)
+	if machineID == "" {
+		return
+	}
+	if sent != "" && resp.StatusCode == http.StatusConflict {
+		f.nonces.forget(machineID, sent)
+		return
+	}
+	if nonce := resp.Header.Get(f.nonceHeader); nonce != "" && resp.StatusCode < 300 {
+		f.nonces.set(machineID, nonce, time.Time{})
+	}
+}
+
+func hasHeader(headers map[string][]string, key string) bool {
+	if len(headers[key]) > 0 {
+		return true
+	}
+	return http.Header(headers).Get(key) != ""
+}
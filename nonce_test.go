@@ -0,0 +1,86 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCachedNonce(t *testing.T) {
+	var sent []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/apps/test-app/machines/m1/lease":
+			if r.Method == http.MethodPost {
+				fmt.Fprintf(w, `{"status":"success","data":{"nonce":"n1","expires_at":%d}}`, time.Now().Add(time.Minute).Unix())
+			}
+		case "/v1/apps/test-app/machines/m1":
+			sent = append(sent, r.Header.Get(NonceHeader))
+			w.Write([]byte(`{"id":"m1"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	ctx := context.Background()
+	update := LaunchMachineInput{ID: "m1", Config: &MachineConfig{Image: "nginx"}}
+
+	ttl := 60
+	if _, err := client.GetLease(ctx, "m1", &ttl); err != nil {
+		t.Fatal(err)
+	}
+	if nonce, ok := client.CachedNonce("m1"); !ok || nonce != "n1" {
+		t.Fatalf("got cached nonce %q, %v, want n1", nonce, ok)
+	}
+	if _, err := client.Update(ctx, update, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Update(ctx, update, "explicit"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.ReleaseLease(ctx, "m1", "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if nonce, ok := client.CachedNonce("m1"); ok {
+		t.Fatalf("got cached nonce %q after release, want none", nonce)
+	}
+	if _, err := client.Update(ctx, update, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"n1", "explicit", ""}; !reflect.DeepEqual(sent, want) {
+		t.Fatalf("updates sent nonces %q, want %q", sent, want)
+	}
+}
+
+func TestCachedNonceFromResponses(t *testing.T) {
+	conflict := false
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if conflict {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":"lease mismatch"}`))
+			return
+		}
+		w.Header().Set(NonceHeader, "n2")
+		w.Write([]byte(`{"id":"m1"}`))
+	})
+	ctx := context.Background()
+
+	if _, err := client.Start(ctx, "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if nonce, ok := client.CachedNonce("m1"); !ok || nonce != "n2" {
+		t.Fatalf("got cached nonce %q, %v, want n2 from the response", nonce, ok)
+	}
+
+	conflict = true
+	if _, err := client.Start(ctx, "m1"); !IsConflict(err) {
+		t.Fatalf("got error %v, want a conflict", err)
+	}
+	if nonce, ok := client.CachedNonce("m1"); ok {
+		t.Fatalf("got cached nonce %q after it was rejected, want none", nonce)
+	}
+}
@@ -0,0 +1,238 @@
+package flaps
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHost sets the host of the Flaps endpoint.
+func WithHost(host string) Option {
+	return func(c *Client) {
+		c.host = host
+	}
+}
+
+// WithToken sets the token requests are authenticated with.
+func WithToken(authToken string) Option {
+	return func(c *Client) {
+		c.authToken = authToken
+	}
+}
+
+// TokenSource returns the token to authenticate a request with. It is called
+// for every request, so it can hand out refreshed tokens, and must be safe
+// for concurrent use.
+type TokenSource func(ctx context.Context) (string, error)
+
+// StaticToken returns a TokenSource that always returns token.
+func StaticToken(token string) TokenSource {
+	return func(context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+// WithTokenSource makes the client fetch the token for each request from
+// source, e.g. to rotate expiring macaroon tokens on a live client. It takes
+// precedence over WithToken.
+func WithTokenSource(source TokenSource) Option {
+	return func(c *Client) {
+		c.tokenSource = source
+	}
+}
+
+// WithApp sets the app whose machines the client manages.
+func WithApp(appName string) Option {
+	return func(c *Client) {
+		c.appName = appName
+	}
+}
+
+// WithOrg sets the organization the client acts within.
+func WithOrg(orgSlug string) Option {
+	return func(c *Client) {
+		c.orgSlug = orgSlug
+	}
+}
+
+// WithHTTPClient sets the HTTP client requests are sent with. It defaults to
+// a client of the Client's own, described at DefaultTransport. See
+// WithTransport for combining the two.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the RoundTripper requests are sent through, e.g. one
+// configured for mTLS or a proxy. Options given with WithHTTPClient, such as
+// its Timeout, are kept: the client is copied with its Transport replaced,
+// whatever the order of the two options. The client passed to WithHTTPClient
+// is never modified.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithBaseURL overrides the URL every request is built against, e.g.
+// "https://api.machines.dev/v1". Machine paths are appended to it.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithScheme sets the URL scheme used to reach the client's host. It defaults
+// to "http"; use "https" for the public api.machines.dev endpoint.
+func WithScheme(scheme string) Option {
+	return func(c *Client) {
+		c.scheme = scheme
+	}
+}
+
+// WithRetry makes the client attempt each request up to maxAttempts times,
+// waiting an exponentially growing, jittered multiple of baseDelay between
+// attempts. Which requests are retried is decided by the RetryPolicy, which
+// defaults to DefaultRetryPolicy.
+func WithRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = maxAttempts
+		c.retryBase = baseDelay
+	}
+}
+
+// WithRetryPolicy overrides which requests WithRetry applies to.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithLogger makes the client trace every request it sends to logger at
+// debug level. The Authorization header is redacted.
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithInstrument registers an Instrument observing every API call the client
+// makes. It may be given several times; instruments run in the order given.
+func WithInstrument(instrument Instrument) Option {
+	return func(c *Client) {
+		c.instruments = append(c.instruments, instrument)
+	}
+}
+
+// WithDefaultTimeout bounds every request whose context has no deadline of
+// its own to d, retries included. Deadlines set by the caller are left alone.
+// Since each of Wait's requests may take up to a minute server-side, d should
+// exceed that when Wait is used without a deadline.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.defaultTimeout = d
+	}
+}
+
+// WithoutValidation stops Launch and Update from validating their input
+// locally, leaving it entirely to the server.
+func WithoutValidation() Option {
+	return func(c *Client) {
+		c.skipValidation = true
+	}
+}
+
+// WithDryRun stops the client from sending mutating requests: calls that
+// would send one fail with an error wrapping ErrDryRun instead, from which
+// DryRunRequest recovers the request. GET and HEAD requests are sent as
+// usual.
+func WithDryRun() Option {
+	return func(c *Client) {
+		c.dryRun = true
+	}
+}
+
+// AllowUnknownStates lets states this package doesn't define through to the
+// server, for use with states added to the API since. By default unknown
+// states are rejected locally, to catch typos.
+func AllowUnknownStates() Option {
+	return func(c *Client) {
+		c.allowUnknownStates = true
+	}
+}
+
+// WithNonceHeader sets the header lease nonces are sent in. It defaults to
+// "fly-machine-lease-nonce".
+func WithNonceHeader(header string) Option {
+	return func(c *Client) {
+		c.nonceHeader = header
+	}
+}
+
+// DefaultMaxResponseBytes is the response size limit of clients that don't
+// set one with WithMaxResponseBytes.
+const DefaultMaxResponseBytes = 4 << 20
+
+// WithMaxResponseBytes caps the size of the response bodies the client reads.
+// Larger successful responses fail with ErrResponseTooLarge, and larger error
+// bodies are truncated.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *Client) {
+		c.maxResponseBytes = n
+	}
+}
+
+// WithUserAgent appends product, e.g. "mytool/1.2", to the client's
+// User-Agent, which defaults to "flaps-go/<version>".
+func WithUserAgent(product string) Option {
+	return func(c *Client) {
+		c.userAgentSuffix = product
+	}
+}
+
+// RequestMiddleware may modify a request just before it is sent, e.g. to sign
+// it. An error aborts the call and is returned from it.
+type RequestMiddleware func(*http.Request) error
+
+// WithRequestMiddleware registers middleware run on every request the client
+// sends, retries included. It may be given several times; middlewares run in
+// the order given.
+func WithRequestMiddleware(middleware RequestMiddleware) Option {
+	return func(c *Client) {
+		c.requestMiddleware = append(c.requestMiddleware, middleware)
+	}
+}
+
+// ResponseMiddleware inspects a response before its status is evaluated. An
+// error aborts the call and is returned from it in place of the response.
+type ResponseMiddleware func(*http.Response) error
+
+// WithResponseMiddleware registers middleware run on every response the
+// client receives, retried ones included. It may be given several times;
+// middlewares run in the order given.
+func WithResponseMiddleware(middleware ResponseMiddleware) Option {
+	return func(c *Client) {
+		c.responseMiddleware = append(c.responseMiddleware, middleware)
+	}
+}
+
+// Limiter paces outgoing requests. *rate.Limiter from golang.org/x/time/rate
+// satisfies it.
+type Limiter interface {
+	// Wait blocks until a request may be sent, or returns an error if ctx
+	// is done first.
+	Wait(ctx context.Context) error
+}
+
+// WithRateLimiter makes the client wait on limiter before sending each
+// request, retries included. Clients don't limit their rate by default.
+func WithRateLimiter(limiter Limiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
@@ -0,0 +1,83 @@
+package flaps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithDefaultTimeout(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {},
+		WithDefaultTimeout(time.Hour),
+		WithRequestMiddleware(func(r *http.Request) error {
+			deadline, hasDeadline = r.Context().Deadline()
+			return nil
+		}),
+	)
+
+	t.Run("no deadline", func(t *testing.T) {
+		start := time.Now()
+		if err := client.Cordon(context.Background(), "m1"); err != nil {
+			t.Fatal(err)
+		}
+		if !hasDeadline {
+			t.Fatal("request context has no deadline")
+		}
+		if deadline.Before(start.Add(time.Hour)) || deadline.After(time.Now().Add(time.Hour)) {
+			t.Fatalf("got deadline %v after the call, want an hour", deadline.Sub(start))
+		}
+	})
+
+	t.Run("shorter deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		want, _ := ctx.Deadline()
+		if err := client.Cordon(ctx, "m1"); err != nil {
+			t.Fatal(err)
+		}
+		if !hasDeadline || !deadline.Equal(want) {
+			t.Fatalf("got deadline %v, want %v", deadline, want)
+		}
+	})
+}
+
+func TestWithDryRun(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"id":"m1","state":"started"}`))
+	}, WithDryRun())
+	ctx := context.Background()
+
+	input := LaunchMachineInput{Config: &MachineConfig{Image: "nginx"}}
+	_, err := client.Launch(ctx, input)
+	if !errors.Is(err, ErrDryRun) {
+		t.Fatalf("got error %v, want ErrDryRun", err)
+	}
+	req, ok := DryRunRequest(err)
+	if !ok {
+		t.Fatal("DryRunRequest found no request")
+	}
+	if want := "/v1/apps/test-app/machines"; req.Method != http.MethodPost || req.URL.Path != want {
+		t.Errorf("got request %s %s, want POST %s", req.Method, req.URL.Path, want)
+	}
+	var sent LaunchMachineInput
+	if err := json.NewDecoder(req.Body).Decode(&sent); err != nil || sent.Config == nil || sent.Config.Image != "nginx" {
+		t.Errorf("got body %+v (%v), want the launch input", sent, err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("dry run sent %q", requests)
+	}
+
+	if _, err := client.Get(ctx, "m1"); err != nil {
+		t.Fatalf("Get in a dry run: %v", err)
+	}
+	if want := []string{"GET /v1/apps/test-app/machines/m1"}; len(requests) != 1 || requests[0] != want[0] {
+		t.Fatalf("got requests %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,149 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+)
+
+// MachineConfigPatch describes changes to a machine's config. Nil fields
+// leave the current values in place.
+//
+// Maps are merged key by key: Env and Metadata entries are added or
+// overwritten, and the keys in RemoveEnv and RemoveMetadata are deleted
+// afterwards. Everything else is replaced as a whole, slices included: a
+// non-nil Services or Mounts becomes the complete new list, so to change one
+// service, pass all of them. An empty, non-nil map or slice clears the field.
+type MachineConfigPatch struct {
+	Image *string
+
+	Env       map[string]string
+	RemoveEnv []string
+
+	Metadata       map[string]string
+	RemoveMetadata []string
+
+	Guest    *MachineGuest
+	Init     *MachineInit
+	Restart  *MachineRestart
+	Metrics  *MachineMetrics
+	Schedule *string
+
+	Services []MachineService
+	Mounts   []MachineMount
+}
+
+// UpdateConfig applies patch to the current config of a machine and updates
+// the machine with the result. The config is read and written in two
+// requests, so concurrent changes can be lost; hold a lease and pass its
+// nonce to rule that out.
+func (f *Client) UpdateConfig(ctx context.Context, machineID string, patch MachineConfigPatch, nonce string) (*Machine, error) {
+	machine, err := f.Get(ctx, machineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update VM %s: %w", machineID, err)
+	}
+
+	config := new(MachineConfig)
+	if machine.Config != nil {
+		if config, err = copyConfig(machine.Config); err != nil {
+			return nil, fmt.Errorf("failed to update VM %s: %w", machineID, err)
+		}
+	}
+	patch.apply(config)
+
+	return f.Update(ctx, LaunchMachineInput{
+		ID:     machineID,
+		Name:   machine.Name,
+		Region: machine.Region,
+		Config: config,
+	}, nonce)
+}
+
+func (p MachineConfigPatch) apply(config *MachineConfig) {
+	if p.Image != nil {
+		config.Image = *p.Image
+	}
+
+	config.Env = mergeMap(config.Env, p.Env, p.RemoveEnv)
+	config.Metadata = mergeMap(config.Metadata, p.Metadata, p.RemoveMetadata)
+
+	if p.Guest != nil {
+		guest := *p.Guest
+		config.Guest = &guest
+	}
+	if p.Init != nil {
+		config.Init = *p.Init
+	}
+	if p.Restart != nil {
+		config.Restart = *p.Restart
+	}
+	if p.Metrics != nil {
+		metrics := *p.Metrics
+		config.Metrics = &metrics
+	}
+	if p.Schedule != nil {
+		config.Schedule = *p.Schedule
+	}
+
+	if p.Services != nil {
+		config.Services = append([]MachineService{}, p.Services...)
+	}
+	if p.Mounts != nil {
+		config.Mounts = append([]MachineMount{}, p.Mounts...)
+	}
+}
+
+// mergeMap sets the entries of set in m, then deletes the keys in remove. An
+// empty, non-nil set clears m.
+func mergeMap(m, set map[string]string, remove []string) map[string]string {
+	if set != nil && len(set) == 0 {
+		return nil
+	}
+	if len(set) > 0 && m == nil {
+		m = make(map[string]string, len(set))
+	}
+	for k, v := range set {
+		m[k] = v
+	}
+	for _, k := range remove {
+		delete(m, k)
+	}
+	return m
+}
+
+// Resize changes the CPUs and memory of a machine, keeping its CPU kind and
+// the rest of its config. Sizes Fly doesn't offer are rejected locally.
+func (f *Client) Resize(ctx context.Context, machineID string, cpus, memoryMB int, nonce string) (*Machine, error) {
+	machine, err := f.Get(ctx, machineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resize VM %s: %w", machineID, err)
+	}
+
+	kind := "shared"
+	if machine.Config != nil && machine.Config.Guest != nil && machine.Config.Guest.CPUKind != "" {
+		kind = machine.Config.Guest.CPUKind
+	}
+	if err := validateGuestSize(kind, cpus, memoryMB); err != nil {
+		return nil, fmt.Errorf("failed to resize VM %s: %w", machineID, err)
+	}
+
+	config := new(MachineConfig)
+	if machine.Config != nil {
+		if config, err = copyConfig(machine.Config); err != nil {
+			return nil, fmt.Errorf("failed to resize VM %s: %w", machineID, err)
+		}
+	}
+	if config.Guest == nil {
+		config.Guest = &MachineGuest{CPUKind: kind}
+	}
+	config.Guest.CPUs = cpus
+	config.Guest.MemoryMB = memoryMB
+	// A preset size would compete with the guest block.
+	config.VMSize = ""
+
+	return f.Update(ctx, LaunchMachineInput{
+		ID:     machineID,
+		Name:   machine.Name,
+		Region: machine.Region,
+		Config: config,
+	}, nonce)
+}
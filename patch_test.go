@@ -0,0 +1,132 @@
+package flaps_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	image := "nginx:2"
+
+	tests := []struct {
+		name  string
+		patch flaps.MachineConfigPatch
+		want  func(*flaps.MachineConfig)
+	}{
+		{"empty patch", flaps.MachineConfigPatch{}, func(*flaps.MachineConfig) {}},
+		{"image", flaps.MachineConfigPatch{Image: &image}, func(c *flaps.MachineConfig) { c.Image = image }},
+		{
+			"merge env",
+			flaps.MachineConfigPatch{Env: map[string]string{"MODE": "replica", "DEBUG": "1"}, RemoveEnv: []string{"PORT"}},
+			func(c *flaps.MachineConfig) { c.Env = map[string]string{"MODE": "replica", "DEBUG": "1"} },
+		},
+		{"clear env", flaps.MachineConfigPatch{Env: map[string]string{}}, func(c *flaps.MachineConfig) { c.Env = nil }},
+		{"clear metadata", flaps.MachineConfigPatch{Metadata: map[string]string{}}, func(c *flaps.MachineConfig) { c.Metadata = nil }},
+		{
+			"replace services",
+			flaps.MachineConfigPatch{Services: []flaps.MachineService{{Protocol: "tcp", InternalPort: 9090}}},
+			func(c *flaps.MachineConfig) {
+				c.Services = []flaps.MachineService{{Protocol: "tcp", InternalPort: 9090}}
+			},
+		},
+		{"clear services", flaps.MachineConfigPatch{Services: []flaps.MachineService{}}, func(c *flaps.MachineConfig) { c.Services = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := flapstest.NewServer()
+			defer server.Close()
+			ctx := context.Background()
+
+			current := &flaps.MachineConfig{
+				Image:    "nginx:1",
+				Env:      map[string]string{"PORT": "8080", "MODE": "primary"},
+				Metadata: map[string]string{"role": "web"},
+				Services: []flaps.MachineService{{Protocol: "tcp", InternalPort: 8080}},
+			}
+			machine, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: current})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.UpdateConfig(ctx, machine.ID, tt.patch, ""); err != nil {
+				t.Fatal(err)
+			}
+			want := machine.Config
+			tt.want(want)
+			got, _ := server.Machine(machine.ID)
+			if diff := flaps.DiffConfig(*want, *got.Config); len(diff) > 0 {
+				t.Fatalf("got config changes %v from the expected config", diff)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	machine, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{
+		Image:  "nginx",
+		VMSize: "performance-1x",
+		Guest:  &flaps.MachineGuest{CPUKind: "performance", CPUs: 1, MemoryMB: 2048},
+		Env:    map[string]string{"PORT": "8080"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Resize(ctx, machine.ID, 2, 4096, ""); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := server.Machine(machine.ID)
+	if want := (flaps.MachineGuest{CPUKind: "performance", CPUs: 2, MemoryMB: 4096}); got.Config.Guest == nil || !reflect.DeepEqual(*got.Config.Guest, want) {
+		t.Errorf("got guest %+v, want %+v", got.Config.Guest, want)
+	}
+	if got.Config.VMSize != "" || got.Config.Env["PORT"] != "8080" {
+		t.Errorf("got size %q and env %v, want the size cleared and the env kept", got.Config.VMSize, got.Config.Env)
+	}
+}
+
+func TestResizeRejectsInvalidSizes(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	machine, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{
+		Image: "nginx",
+		Guest: &flaps.MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: 256},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		cpus, memoryMB int
+		wantErr        string
+	}{
+		{"unsupported cpu count", 3, 768, "3 shared cpus are not offered"},
+		{"too little memory", 2, 256, "between 512 and 4096 MB"},
+		{"too much memory", 1, 4096, "between 256 and 2048 MB"},
+		{"memory not in steps", 1, 300, "in 256 MB steps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Resize(ctx, machine.ID, tt.cpus, tt.memoryMB, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, _ := server.Machine(machine.ID)
+	if got.Config.Guest.CPUs != 1 || got.Config.Guest.MemoryMB != 256 {
+		t.Fatalf("got guest %+v, want it unchanged", got.Config.Guest)
+	}
+}
@@ -0,0 +1,29 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const pingTimeout = 5 * time.Second
+
+// Ping checks that the Flaps endpoint is reachable and accepts the client's
+// token, by listing at most one of the app's machines.
+func (f *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	err := f.sendRequest(ctx, "Ping", http.MethodGet, "?limit=1", nil, nil, nil)
+	switch {
+	case err == nil:
+		return nil
+	case IsUnauthorized(err):
+		return fmt.Errorf("flaps rejected the auth token: %w", err)
+	case StatusCode(err) != 0:
+		return fmt.Errorf("flaps returned an error: %w", err)
+	default:
+		return fmt.Errorf("cannot reach flaps at %s: %w", f.BaseURL(), err)
+	}
+}
@@ -0,0 +1,57 @@
+package flaps
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStatus is the rate limit state last reported by Flaps.
+type RateLimitStatus struct {
+	Limit     int
+	Remaining int
+	// Reset is when the limit is replenished.
+	Reset time.Time
+	// ObservedAt is when the state was reported, and is zero if no response
+	// has carried rate limit headers yet.
+	ObservedAt time.Time
+}
+
+// RateLimit returns the rate limit state reported by the latest response
+// that carried x-ratelimit-* headers. It is safe to call concurrently with
+// requests.
+func (f *Client) RateLimit() RateLimitStatus {
+	f.rateLimit.mu.Lock()
+	defer f.rateLimit.mu.Unlock()
+	return f.rateLimit.status
+}
+
+type rateLimitTracker struct {
+	mu     sync.Mutex
+	status RateLimitStatus
+}
+
+// observe records the rate limit headers of a response, if it has any.
+func (t *rateLimitTracker) observe(header http.Header) {
+	limit, limitErr := strconv.Atoi(header.Get("X-Ratelimit-Limit"))
+	remaining, remainingErr := strconv.Atoi(header.Get("X-Ratelimit-Remaining"))
+	if limitErr != nil && remainingErr != nil {
+		return
+	}
+
+	now := time.Now()
+	status := RateLimitStatus{Limit: limit, Remaining: remaining, ObservedAt: now}
+	if reset, err := strconv.ParseInt(header.Get("X-Ratelimit-Reset"), 10, 64); err == nil {
+		// Servers send either the number of seconds left or a unix time.
+		if reset > 1e9 {
+			status.Reset = time.Unix(reset, 0)
+		} else {
+			status.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	t.mu.Lock()
+	t.status = status
+	t.mu.Unlock()
+}
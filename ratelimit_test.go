@@ -0,0 +1,130 @@
+package flaps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		header      http.Header
+		want        RateLimitStatus
+		wantResetIn time.Duration
+	}{
+		{
+			"seconds left",
+			http.Header{"X-Ratelimit-Limit": {"100"}, "X-Ratelimit-Remaining": {"42"}, "X-Ratelimit-Reset": {"30"}},
+			RateLimitStatus{Limit: 100, Remaining: 42},
+			30 * time.Second,
+		},
+		{
+			"unix time",
+			http.Header{"X-Ratelimit-Limit": {"100"}, "X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {strconv.FormatInt(reset.Unix(), 10)}},
+			RateLimitStatus{Limit: 100, Remaining: 0, Reset: reset},
+			0,
+		},
+		{
+			"no reset",
+			http.Header{"X-Ratelimit-Remaining": {"7"}},
+			RateLimitStatus{Remaining: 7},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range tt.header {
+					w.Header()[key] = values
+				}
+			})
+
+			if got := client.RateLimit(); !got.ObservedAt.IsZero() {
+				t.Fatalf("got status %+v before any response, want none", got)
+			}
+			start := time.Now()
+			if err := client.Cordon(context.Background(), "m1"); err != nil {
+				t.Fatal(err)
+			}
+			got := client.RateLimit()
+
+			if got.ObservedAt.Before(start) || got.ObservedAt.After(time.Now()) {
+				t.Errorf("got ObservedAt %v, want the time of the call", got.ObservedAt)
+			}
+			if tt.wantResetIn > 0 {
+				if want := got.ObservedAt.Add(tt.wantResetIn); !got.Reset.Equal(want) {
+					t.Errorf("got Reset %v, want %v", got.Reset, want)
+				}
+				got.Reset = time.Time{}
+			}
+			got.ObservedAt = time.Time{}
+			if got != tt.want {
+				t.Errorf("got status %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresResponsesWithoutHeaders(t *testing.T) {
+	withHeaders := true
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if withHeaders {
+			w.Header().Set("X-Ratelimit-Limit", "100")
+			w.Header().Set("X-Ratelimit-Remaining", "99")
+		}
+	})
+	ctx := context.Background()
+
+	if err := client.Cordon(ctx, "m1"); err != nil {
+		t.Fatal(err)
+	}
+	withHeaders = false
+	if err := client.Cordon(ctx, "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.RateLimit(); got.Limit != 100 || got.Remaining != 99 {
+		t.Fatalf("got status %+v, want the one from the first response", got)
+	}
+}
+
+type stubLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *stubLimiter) Wait(ctx context.Context) error {
+	l.calls++
+	return l.err
+}
+
+func TestWithRateLimiter(t *testing.T) {
+	var requests int
+	limiter := &stubLimiter{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}, WithRateLimiter(limiter))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := client.Cordon(ctx, "m1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if limiter.calls != 2 || requests != 2 {
+		t.Fatalf("got %d waits for %d requests, want 2 of each", limiter.calls, requests)
+	}
+
+	limiter.err = errors.New("rate: Wait(n=1) would exceed context deadline")
+	if err := client.Cordon(ctx, "m1"); !errors.Is(err, limiter.err) {
+		t.Fatalf("got error %v, want the limiter's", err)
+	}
+	if requests != 2 {
+		t.Fatalf("a request was sent although the limiter failed")
+	}
+}
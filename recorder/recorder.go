@@ -0,0 +1,233 @@
+// Package recorder records the Flaps API traffic of a flaps.Client to a file
+// and replays it later, so tests written against the real API can run
+// offline and deterministically.
+//
+//	rec, err := recorder.New("testdata/launch.json", recorder.Replay, nil)
+//	...
+//	client, err := flaps.NewClient(flaps.WithTransport(rec), ...)
+//
+// Requests are matched on their method, path, query and a hash of their body.
+// Identical requests are replayed in the order they were recorded. Headers
+// are not part of the match. Neither request headers nor request bodies are
+// written to the file, only the hash of the body, so tokens and the secrets
+// of launched configs stay out of recordings. Responses are recorded as
+// received, bar gzip encoding, and can hold machine configs: review them
+// before committing them.
+package recorder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Mode int
+
+const (
+	// Record sends requests to the real API and keeps every exchange, to be
+	// written by Save.
+	Record Mode = iota
+	// Replay answers requests from a recording, without touching the
+	// network, and fails requests that weren't recorded.
+	Replay
+)
+
+// Interaction is a recorded request/response pair.
+type Interaction struct {
+	Key      string   `json:"key"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request describes a recorded request. Its body is only recorded as part
+// of the interaction's key.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that records or replays exchanges.
+type Recorder struct {
+	mode Mode
+	path string
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	// replayed counts the interactions replayed so far per key.
+	replayed map[string]int
+}
+
+// New returns a Recorder working with the recording at path. In Record mode
+// requests are sent through next, which defaults to http.DefaultTransport.
+// In Replay mode the recording is loaded right away and next is unused.
+func New(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{
+		mode:     mode,
+		path:     path,
+		next:     next,
+		replayed: make(map[string]int),
+	}
+
+	if mode == Replay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load recording: %w", err)
+		}
+		if err := json.Unmarshal(b, &r.interactions); err != nil {
+			return nil, fmt.Errorf("failed to load recording %s: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	key := requestKey(req, body)
+
+	if r.mode == Replay {
+		return r.replay(req, key)
+	}
+	return r.record(req, key, body)
+}
+
+func (r *Recorder) record(req *http.Request, key string, body []byte) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	header := resp.Header.Clone()
+	if respBody, err = decompress(header, respBody); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, &Interaction{
+		Key: key,
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.RequestURI(),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     header.Clone(),
+			Body:       string(respBody),
+		},
+	})
+	r.mu.Unlock()
+
+	resp.Header = header
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	resp.ContentLength = int64(len(respBody))
+	return resp, nil
+}
+
+// decompress returns body decoded if header says it is gzip-encoded, and
+// drops the encoding from header. Recordings keep bodies as text, which
+// compressed bytes wouldn't survive.
+func decompress(header http.Header, body []byte) ([]byte, error) {
+	if !strings.EqualFold(header.Get("Content-Encoding"), "gzip") || len(body) == 0 {
+		return body, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress response: %w", err)
+	}
+	defer reader.Close()
+	body, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress response: %w", err)
+	}
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return body, nil
+}
+
+func (r *Recorder) replay(req *http.Request, key string) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	skip := r.replayed[key]
+	for _, interaction := range r.interactions {
+		if interaction.Key != key {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		r.replayed[key]++
+
+		recorded := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, req.URL.RequestURI(), r.path)
+}
+
+// Save writes the exchanges recorded so far to the recording file. It is an
+// error in Replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != Record {
+		return errors.New("only recordings made in Record mode can be saved")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o644)
+}
+
+// requestKey identifies a request by its method, path, query and body.
+func requestKey(req *http.Request, body []byte) string {
+	sum := sha256.Sum256(body)
+	return req.Method + " " + req.URL.RequestURI() + " " + hex.EncodeToString(sum[:8])
+}
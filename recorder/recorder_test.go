@@ -0,0 +1,121 @@
+package recorder_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/recorder"
+)
+
+func newClient(t *testing.T, baseURL string, rec *recorder.Recorder) *flaps.Client {
+	t.Helper()
+	client, err := flaps.NewClient(
+		flaps.WithBaseURL(baseURL+"/v1"),
+		flaps.WithToken("test-token"),
+		flaps.WithApp("test-app"),
+		flaps.WithTransport(rec),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRecordReplayGzip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		json.NewEncoder(gz).Encode(flaps.Machine{ID: "m1", State: "started"})
+		gz.Close()
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+
+	rec, err := recorder.New(path, recorder.Record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := newClient(t, server.URL, rec).Get(context.Background(), "m1")
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if recorded.ID != "m1" {
+		t.Fatalf("record: got machine %+v", recorded)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	replay, err := recorder.New(path, recorder.Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, server.URL, replay)
+	replayed, err := client.Get(context.Background(), "m1")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed.ID != "m1" || replayed.State != "started" {
+		t.Fatalf("replay: got machine %+v", replayed)
+	}
+
+	if _, err := client.Get(context.Background(), "m2"); err == nil {
+		t.Fatal("replay: unrecorded request succeeded")
+	}
+}
+
+func TestRecordingOmitsRequestBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(flaps.Machine{ID: "m1", State: "started"})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+	input := flaps.LaunchMachineInput{Config: &flaps.MachineConfig{
+		Image: "nginx",
+		Env:   map[string]string{"DATABASE_PASSWORD": "hunter2"},
+	}}
+
+	rec, err := recorder.New(path, recorder.Record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newClient(t, server.URL, rec).Launch(context.Background(), input); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "hunter2") || strings.Contains(string(b), "test-token") {
+		t.Fatalf("recording contains secrets:\n%s", b)
+	}
+	server.Close()
+
+	replay, err := recorder.New(path, recorder.Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, server.URL, replay)
+	if _, err := client.Launch(context.Background(), input); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	input.Config.Env["DATABASE_PASSWORD"] = "other"
+	if _, err := client.Launch(context.Background(), input); err == nil {
+		t.Fatal("replay: a launch with another body matched the recording")
+	}
+}
@@ -0,0 +1,107 @@
+package flaps
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy reports whether a request should be attempted again after the
+// previous attempt returned resp and err. resp is nil when err is not.
+type RetryPolicy func(req *http.Request, resp *http.Response, err error) bool
+
+// DefaultRetryPolicy retries GET requests, such as get, list and wait, that
+// failed in transit or got a 429 or 5xx response. Other requests aren't
+// retried, so that, for instance, a launch can't create duplicate machines.
+func DefaultRetryPolicy(req *http.Request, resp *http.Response, err error) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return retryable(req, resp, err)
+}
+
+// IdempotentRetryPolicy is like DefaultRetryPolicy, but also retries
+// requests of other methods that carry an idempotency key. Flaps doesn't
+// document honoring idempotency keys, so only use it against a server known
+// to return the original result for a repeated key, or a retried launch may
+// create a second machine.
+func IdempotentRetryPolicy(req *http.Request, resp *http.Response, err error) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead && req.Header.Get(IdempotencyKeyHeader) == "" {
+		return false
+	}
+	return retryable(req, resp, err)
+}
+
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+const maxRetryDelay = 30 * time.Second
+
+func (f *Client) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	policy := f.retryPolicy
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	return policy(req, resp, err)
+}
+
+// retryDelay returns how long to wait before the attempt following attempt.
+func (f *Client) retryDelay(attempt int) time.Duration {
+	return backoff(f.retryBase, attempt)
+}
+
+// backoff returns base doubled for each attempt after the first, capped, and
+// jittered into its upper half.
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value in either its
+// delay-seconds or HTTP-date form.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
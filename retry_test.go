@@ -0,0 +1,41 @@
+package flaps
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIdempotencyKeyRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		opts         []CallOption
+		wantRequests int32
+	}{
+		{"default policy", DefaultRetryPolicy, []CallOption{WithIdempotencyKey("k1")}, 1},
+		{"idempotent policy", IdempotentRetryPolicy, []CallOption{WithIdempotencyKey("k1")}, 2},
+		{"idempotent policy without key", IdempotentRetryPolicy, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				if got := r.Header.Get(IdempotencyKeyHeader); got != "k1" {
+					t.Errorf("retried with %s %q, want k1", IdempotencyKeyHeader, got)
+				}
+				w.Write([]byte(`{"id":"m1"}`))
+			}, WithRetry(3, 0), WithRetryPolicy(tt.policy))
+
+			client.Launch(context.Background(), LaunchMachineInput{Config: &MachineConfig{Image: "nginx"}}, tt.opts...)
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Fatalf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package flaps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RollingUpdateOptions struct {
+	// LeaseTTL is the TTL of the lease held on the machine for the duration
+	// of the update. It defaults to 30s.
+	LeaseTTL time.Duration
+	// Timeout bounds how long the updated machine may take to start and
+	// pass its health checks. It defaults to 5 minutes.
+	Timeout time.Duration
+	// CheckInterval is how often health checks are polled. It defaults to 2s.
+	CheckInterval time.Duration
+	// Rollback restores the previous config of the machine when the update
+	// fails to start or pass its checks.
+	Rollback bool
+}
+
+// RollingUpdate updates a machine safely: it leases the machine, updates it
+// with input, waits for it to start and for all of its health checks to
+// pass, then releases the lease. With opts.Rollback set, a machine that
+// doesn't come up healthy is updated back to its previous config, and the
+// error says so.
+func (f *Client) RollingUpdate(ctx context.Context, input LaunchMachineInput, opts RollingUpdateOptions) (machine *Machine, err error) {
+	if input.ID == "" {
+		return nil, errors.New("failed to update VM: id is required")
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = 30 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = 2 * time.Second
+	}
+
+	lease, err := f.HoldLease(ctx, input.ID, opts.LeaseTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update VM %s: %w", input.ID, err)
+	}
+	defer func() {
+		if releaseErr := lease.Release(); releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to release lease on VM %s: %w", input.ID, releaseErr))
+		}
+	}()
+
+	previous, err := f.Get(ctx, input.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update VM %s: %w", input.ID, err)
+	}
+
+	machine, err = f.Update(ctx, input, lease.Nonce())
+	if err != nil {
+		return nil, err
+	}
+
+	healthyCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	machine, err = f.waitHealthy(healthyCtx, machine, opts.CheckInterval)
+	if err == nil {
+		return machine, nil
+	}
+	if !opts.Rollback {
+		return machine, err
+	}
+
+	rolledBack, rollbackErr := f.rollBack(ctx, previous, lease.Nonce(), opts)
+	if rollbackErr != nil {
+		return machine, errors.Join(err, fmt.Errorf("failed to roll back VM %s: %w", input.ID, rollbackErr))
+	}
+	return rolledBack, fmt.Errorf("rolled back VM %s to version %s: %w", input.ID, previous.WaitInstanceID(), err)
+}
+
+// waitHealthy waits for machine to start, then polls it every interval until
+// all of its health checks pass. It returns the machine as last fetched.
+func (f *Client) waitHealthy(ctx context.Context, machine *Machine, interval time.Duration) (*Machine, error) {
+	if err := f.Wait(ctx, machine, StateStarted, 0); err != nil {
+		return machine, err
+	}
+
+	for {
+		current, err := f.Get(ctx, machine.ID)
+		if err != nil {
+			return machine, err
+		}
+		machine = current
+		if machine.AllChecksPassing() {
+			return machine, nil
+		}
+		if err := sleepContext(ctx, interval); err != nil {
+			return machine, fmt.Errorf("VM %s checks not passing: %s: %w", machine.ID, strings.Join(machine.FailingChecks(), ", "), err)
+		}
+	}
+}
+
+// rollBack updates machine back to its previous config and waits for it to
+// start. It runs even once ctx is done, within opts.Timeout.
+func (f *Client) rollBack(ctx context.Context, previous *Machine, nonce string, opts RollingUpdateOptions) (*Machine, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), opts.Timeout)
+	defer cancel()
+
+	machine, err := f.Update(ctx, LaunchMachineInput{
+		ID:     previous.ID,
+		Name:   previous.Name,
+		Region: previous.Region,
+		Config: previous.Config,
+	}, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Wait(ctx, machine, StateStarted, 0); err != nil {
+		return machine, err
+	}
+	return machine, nil
+}
+
+// UpdateStrategy is how UpdateGroup rolls an update out.
+type UpdateStrategy string
+
+const (
+	// UpdateImmediate updates every machine at once, without waiting for
+	// them to come up healthy.
+	UpdateImmediate UpdateStrategy = "immediate"
+	// UpdateRolling updates one machine at a time with RollingUpdate,
+	// stopping at the first that fails.
+	UpdateRolling UpdateStrategy = "rolling"
+	// UpdateCanary updates the first machine with RollingUpdate and, once it
+	// is healthy, the rest at once, each health-gated the same way.
+	UpdateCanary UpdateStrategy = "canary"
+)
+
+type GroupUpdateOptions struct {
+	// Machine configures the RollingUpdate of each machine, under the
+	// rolling and canary strategies.
+	Machine RollingUpdateOptions
+	// Concurrency caps the updates in flight at once under the immediate
+	// strategy and after the canary. It defaults to all machines at once.
+	Concurrency int
+}
+
+// UpdateGroup updates the machines of ids with input's config following
+// strategy. Each machine keeps its own name and region. Under the rolling and
+// canary strategies, once an update fails no further ones are started; the
+// error names the machines left alone along with every failure.
+func (f *Client) UpdateGroup(ctx context.Context, ids []string, input LaunchMachineInput, strategy UpdateStrategy, opts GroupUpdateOptions) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	switch strategy {
+	case UpdateImmediate:
+		return f.updateConcurrently(ctx, ids, input, opts.Concurrency, false, func(ctx context.Context, in LaunchMachineInput) error {
+			_, err := f.Update(ctx, in, "")
+			return err
+		})
+	case UpdateRolling:
+		for i, id := range ids {
+			if err := f.updateMember(ctx, id, input, opts.Machine); err != nil {
+				return skippedUpdates(err, ids[i+1:])
+			}
+		}
+		return nil
+	case UpdateCanary:
+		if err := f.updateMember(ctx, ids[0], input, opts.Machine); err != nil {
+			return skippedUpdates(fmt.Errorf("canary: %w", err), ids[1:])
+		}
+		return f.updateConcurrently(ctx, ids[1:], input, opts.Concurrency, true, func(ctx context.Context, in LaunchMachineInput) error {
+			_, err := f.RollingUpdate(ctx, in, opts.Machine)
+			return err
+		})
+	default:
+		return fmt.Errorf("unknown update strategy %q", strategy)
+	}
+}
+
+// updateMember health-gates the update of one machine of a group.
+func (f *Client) updateMember(ctx context.Context, id string, input LaunchMachineInput, opts RollingUpdateOptions) error {
+	in, err := f.memberInput(ctx, id, input)
+	if err != nil {
+		return err
+	}
+	_, err = f.RollingUpdate(ctx, in, opts)
+	return err
+}
+
+// updateConcurrently runs update for each of ids from a pool of concurrency
+// workers and joins the failures. With abortOnError, no further updates are
+// started after the first failure; those in flight run to completion, and
+// the machines left out are named in the error.
+func (f *Client) updateConcurrently(ctx context.Context, ids []string, input LaunchMachineInput, concurrency int, abortOnError bool, update func(context.Context, LaunchMachineInput) error) error {
+	if concurrency < 1 {
+		concurrency = len(ids)
+	}
+
+	// Cancelling dispatchCtx stops new updates without interrupting those
+	// already running, which still run under ctx.
+	dispatchCtx, abort := context.WithCancel(ctx)
+	defer abort()
+
+	var (
+		mu      sync.Mutex
+		started = make(map[string]bool, len(ids))
+	)
+	results := forEachMachine(dispatchCtx, ids, concurrency, func(_ context.Context, id string) error {
+		if err := dispatchCtx.Err(); err != nil {
+			return err
+		}
+		mu.Lock()
+		started[id] = true
+		mu.Unlock()
+
+		in, err := f.memberInput(ctx, id, input)
+		if err == nil {
+			err = update(ctx, in)
+		}
+		if err != nil && abortOnError {
+			abort()
+		}
+		return err
+	})
+
+	var (
+		errs    []error
+		skipped []string
+	)
+	for _, id := range ids {
+		err := results[id]
+		switch {
+		case err == nil:
+		case !started[id] && ctx.Err() == nil:
+			skipped = append(skipped, id)
+		default:
+			errs = append(errs, fmt.Errorf("machine %s: %w", id, err))
+		}
+	}
+	return skippedUpdates(errors.Join(errs...), skipped)
+}
+
+// memberInput returns input aimed at the machine id, with its name and
+// region.
+func (f *Client) memberInput(ctx context.Context, id string, input LaunchMachineInput) (LaunchMachineInput, error) {
+	machine, err := f.Get(ctx, id)
+	if err != nil {
+		return LaunchMachineInput{}, fmt.Errorf("failed to update VM %s: %w", id, err)
+	}
+	input.ID = id
+	input.Name = machine.Name
+	input.Region = machine.Region
+	return input, nil
+}
+
+func skippedUpdates(err error, skipped []string) error {
+	if len(skipped) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w; not updated: %s", err, strings.Join(skipped, ", "))
+}
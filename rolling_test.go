@@ -0,0 +1,137 @@
+package flaps_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikefrey/flaps"
+	"github.com/mikefrey/flaps/flapstest"
+)
+
+func TestUpdateGroupAbortsOnFailure(t *testing.T) {
+	for _, strategy := range []flaps.UpdateStrategy{flaps.UpdateRolling, flaps.UpdateCanary} {
+		t.Run(string(strategy), func(t *testing.T) {
+			server, client := flapstest.NewServer()
+			defer server.Close()
+			ctx := context.Background()
+
+			var ids []string
+			for i := 0; i < 4; i++ {
+				machine, err := client.Launch(ctx, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx:1"}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, machine.ID)
+			}
+
+			// A lease taken by another client, whose nonce client doesn't
+			// know, makes the update of the second machine fail.
+			other, err := server.NewClient(flapstest.AppName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ttl := 60
+			if _, err := other.GetLease(ctx, ids[1], &ttl); err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.UpdateGroup(ctx, ids, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx:2"}}, strategy, flaps.GroupUpdateOptions{
+				Machine:     flaps.RollingUpdateOptions{CheckInterval: time.Millisecond},
+				Concurrency: 1,
+			})
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if !strings.Contains(err.Error(), "not updated: "+ids[2]+", "+ids[3]) {
+				t.Errorf("error doesn't name the skipped machines: %v", err)
+			}
+
+			wantImages := []string{"nginx:2", "nginx:1", "nginx:1", "nginx:1"}
+			for i, id := range ids {
+				machine, _ := server.Machine(id)
+				if machine.Config.Image != wantImages[i] {
+					t.Errorf("machine %d has image %s, want %s", i, machine.Config.Image, wantImages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateGroupImmediate(t *testing.T) {
+	server, client := flapstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		machine, err := client.Launch(ctx, flaps.LaunchMachineInput{Name: "web", Region: "ord", Config: &flaps.MachineConfig{Image: "nginx:1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, machine.ID)
+	}
+
+	err := client.UpdateGroup(ctx, ids, flaps.LaunchMachineInput{Config: &flaps.MachineConfig{Image: "nginx:2"}}, flaps.UpdateImmediate, flaps.GroupUpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		machine, _ := server.Machine(id)
+		if machine.Config.Image != "nginx:2" || machine.Name != "web" {
+			t.Errorf("machine %s: got image %s and name %s", id, machine.Config.Image, machine.Name)
+		}
+	}
+}
+
+func TestRollingUpdateRollback(t *testing.T) {
+	// The server only sets instance_id, as current Flaps does, and the
+	// updated config never passes its checks.
+	image := "nginx:1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/apps/test-app/machines/m1/lease":
+			w.Write([]byte(`{"status":"success","data":{"nonce":"n1"}}`))
+		case r.URL.Path == "/v1/apps/test-app/machines/m1/wait":
+			w.Write([]byte(`{"ok":true}`))
+		case r.URL.Path == "/v1/apps/test-app/machines/m1":
+			if r.Method == http.MethodPost {
+				var in flaps.LaunchMachineInput
+				json.NewDecoder(r.Body).Decode(&in)
+				image = in.Config.Image
+			}
+			machine := flaps.Machine{ID: "m1", State: "started", InstanceID: "i-" + image, Config: &flaps.MachineConfig{Image: image}}
+			if image != "nginx:1" {
+				machine.Checks = []*flaps.MachineCheckStatus{{Name: "http", Status: "critical"}}
+			}
+			json.NewEncoder(w).Encode(machine)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := flaps.NewClient(flaps.WithBaseURL(server.URL+"/v1"), flaps.WithToken("test-token"), flaps.WithApp("test-app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	machine, err := client.RollingUpdate(context.Background(), flaps.LaunchMachineInput{ID: "m1", Config: &flaps.MachineConfig{Image: "nginx:2"}}, flaps.RollingUpdateOptions{
+		Timeout:       100 * time.Millisecond,
+		CheckInterval: 10 * time.Millisecond,
+		Rollback:      true,
+	})
+	if err == nil {
+		t.Fatal("got no error from an update that didn't pass its checks")
+	}
+	if want := "rolled back VM m1 to version i-nginx:1: "; !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("got error %q, want it to start with %q", err, want)
+	}
+	if machine.Config.Image != "nginx:1" {
+		t.Fatalf("got image %s after the rollback, want nginx:1", machine.Config.Image)
+	}
+}
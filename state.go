@@ -0,0 +1,66 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MachineState is the lifecycle state of a machine.
+type MachineState string
+
+const (
+	StateCreated    MachineState = "created"
+	StateStarting   MachineState = "starting"
+	StateStarted    MachineState = "started"
+	StateStopping   MachineState = "stopping"
+	StateStopped    MachineState = "stopped"
+	StateSuspending MachineState = "suspending"
+	StateSuspended  MachineState = "suspended"
+	StateReplacing  MachineState = "replacing"
+	StateDestroying MachineState = "destroying"
+	StateDestroyed  MachineState = "destroyed"
+	StateFailed     MachineState = "failed"
+)
+
+var knownStates = map[MachineState]bool{
+	StateCreated:    true,
+	StateStarting:   true,
+	StateStarted:    true,
+	StateStopping:   true,
+	StateStopped:    true,
+	StateSuspending: true,
+	StateSuspended:  true,
+	StateReplacing:  true,
+	StateDestroying: true,
+	StateDestroyed:  true,
+	StateFailed:     true,
+}
+
+// Known reports whether s is one of the states defined by this package.
+func (s MachineState) Known() bool {
+	return knownStates[s]
+}
+
+// checkState returns an error for states the client doesn't know, unless
+// AllowUnknownStates is set. The empty state is left to the caller's default.
+func (f *Client) checkState(state MachineState) error {
+	if state == "" || f.allowUnknownStates || state.Known() {
+		return nil
+	}
+	return fmt.Errorf("unknown machine state %q", state)
+}
+
+// WaitStarted waits for the machine to reach the started state, as
+// WaitForState does.
+func (f *Client) WaitStarted(ctx context.Context, machineID string, timeout time.Duration) error {
+	_, err := f.WaitForState(ctx, machineID, StateStarted, timeout)
+	return err
+}
+
+// WaitStopped waits for the machine to reach the stopped state, as
+// WaitForState does.
+func (f *Client) WaitStopped(ctx context.Context, machineID string, timeout time.Duration) error {
+	_, err := f.WaitForState(ctx, machineID, StateStopped, timeout)
+	return err
+}
@@ -0,0 +1,98 @@
+package flaps
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTransport returns a transport configured like the one clients use
+// when given neither WithHTTPClient nor WithTransport. It starts from
+// http.DefaultTransport's settings (proxy from the environment, dial and TLS
+// handshake timeouts, HTTP/2) and:
+//
+//   - keeps up to 10 idle connections to the API host, closing them after 90s
+//     unused;
+//   - fails requests whose response headers take over 90s, which leaves room
+//     for Wait requests, held by the server for up to 60s.
+//
+// Each client gets a transport of its own, so its connections aren't shared
+// with the rest of the process.
+func DefaultTransport() *http.Transport {
+	var transport *http.Transport
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	} else {
+		// http.DefaultTransport was replaced by something else.
+		transport = &http.Transport{Proxy: http.ProxyFromEnvironment, ForceAttemptHTTP2: true}
+	}
+	transport.MaxIdleConnsPerHost = 10
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.ResponseHeaderTimeout = 90 * time.Second
+	return transport
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: DefaultTransport()}
+}
+
+// Close releases the leases the client holds through HoldLease, stopping
+// their renewal, and closes the idle connections of the client's own
+// transport. A client given an HTTP client or transport leaves its
+// connections to its owner. Clients returned by ForApp and ForOrg share these
+// with the client they came from.
+func (f *Client) Close() error {
+	var errs []error
+	for _, l := range f.leases.all() {
+		if err := l.Release(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release lease on VM %s: %w", l.MachineID, err))
+		}
+	}
+	if f.ownsTransport {
+		f.httpClient.CloseIdleConnections()
+	}
+	return errors.Join(errs...)
+}
+
+// decompressBody replaces the body of a gzip-encoded response with its
+// decompressed form. Requests ask for gzip themselves, so transports don't
+// decompress responses on their own.
+func decompressBody(resp *http.Response) error {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return nil
+	}
+	if resp.ContentLength == 0 {
+		return nil
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("failed to decompress response: %w", err)
+	}
+	resp.Body = &gzipBody{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
@@ -0,0 +1,30 @@
+package flaps
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGzipResponse(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept-Encoding"); got != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		json.NewEncoder(gz).Encode(Machine{ID: "m1", State: "started"})
+		gz.Close()
+	})
+
+	machine, err := client.Get(context.Background(), "m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if machine.ID != "m1" || machine.State != "started" {
+		t.Fatalf("got machine %+v", machine)
+	}
+}
@@ -1,11 +1,27 @@
 package flaps
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
 	"syscall"
 	"time"
 )
 
+type App struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	Status       string          `json:"status"`
+	Organization AppOrganization `json:"organization"`
+}
+
+type AppOrganization struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
 type Machine struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
@@ -28,7 +44,8 @@ type Machine struct {
 
 	Config *MachineConfig `json:"config"`
 
-	Events     []*MachineEvent `json:"events,omitempty"`
+	Events     []*MachineEvent       `json:"events,omitempty"`
+	Checks     []*MachineCheckStatus `json:"checks,omitempty"`
 	LeaseNonce string
 }
 
@@ -47,6 +64,79 @@ func (m Machine) ImageRepository() string {
 	return m.ImageRef.Repository
 }
 
+// MetadataProcessGroup is the metadata key holding the process group, e.g.
+// "web" or "worker", a machine runs.
+const MetadataProcessGroup = "fly_process_group"
+
+// ProcessGroup returns the process group the machine runs, or "" if its config
+// doesn't name one.
+func (m Machine) ProcessGroup() string {
+	if m.Config == nil {
+		return ""
+	}
+	return m.Config.Metadata[MetadataProcessGroup]
+}
+
+// CreatedTime parses CreatedAt. It returns the zero time when CreatedAt is
+// empty.
+func (m Machine) CreatedTime() (time.Time, error) {
+	return parseTimestamp(m.CreatedAt)
+}
+
+// UpdatedTime parses UpdatedAt, like CreatedTime.
+func (m Machine) UpdatedTime() (time.Time, error) {
+	return parseTimestamp(m.UpdatedAt)
+}
+
+// parseTimestamp parses a Flaps timestamp, which is RFC 3339 in most places
+// and unix milliseconds in some.
+func parseTimestamp(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// WaitInstanceID returns the ID of the machine version Wait waits on: Version
+// when it is set, as by older Flaps responses, and InstanceID otherwise.
+func (m Machine) WaitInstanceID() string {
+	if m.Version != "" {
+		return m.Version
+	}
+	return m.InstanceID
+}
+
+// PrivateIPAddr parses PrivateIP. It reports false while the machine has no
+// address assigned yet.
+func (m Machine) PrivateIPAddr() (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(m.PrivateIP)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
+
+// AllChecksPassing reports whether every health check of the machine is
+// passing. A machine without checks passes.
+func (m Machine) AllChecksPassing() bool {
+	return len(m.FailingChecks()) == 0
+}
+
+// FailingChecks returns the names of the machine's health checks that aren't
+// passing, including those in the warning state.
+func (m Machine) FailingChecks() []string {
+	var failing []string
+	for _, check := range m.Checks {
+		if check.Status != "passing" {
+			failing = append(failing, check.Name)
+		}
+	}
+	return failing
+}
+
 type machineImageRef struct {
 	Registry   string            `json:"registry"`
 	Repository string            `json:"repository"`
@@ -55,7 +145,14 @@ type machineImageRef struct {
 	Labels     map[string]string `json:"labels"`
 }
 
+type MachineVersion struct {
+	Version    string          `json:"version"`
+	UserErrors []string        `json:"user_errors,omitempty"`
+	ImageRef   machineImageRef `json:"image_ref"`
+}
+
 type MachineEvent struct {
+	ID        string          `json:"id,omitempty"`
 	Type      string          `json:"type"`
 	Status    string          `json:"status"`
 	Request   *MachineRequest `json:"request,omitempty"`
@@ -63,6 +160,20 @@ type MachineEvent struct {
 	Timestamp int64           `json:"timestamp"`
 }
 
+// Time returns the event's Timestamp, which Flaps reports in unix
+// milliseconds.
+func (e MachineEvent) Time() time.Time {
+	return time.UnixMilli(e.Timestamp)
+}
+
+type MachineCheckStatus struct {
+	Name string `json:"name"`
+	// Status is "passing", "warning" or "critical".
+	Status    string `json:"status"`
+	Output    string `json:"output,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
 type MachineRequest struct {
 	ExitEvent    *MachineExitEvent `json:"exit_event,omitempty"`
 	RestartCount int64             `json:"restart_count"`
@@ -77,13 +188,98 @@ type MachineExitEvent struct {
 	Signal        int16 `json:"signal"`
 }
 
+// ListFilter narrows the machines List returns. Zero fields don't filter.
+type ListFilter struct {
+	State  MachineState
+	Region string
+	// Metadata matches machines carrying all of the given key/value pairs.
+	// Keys and values must not be empty.
+	Metadata map[string]string
+	// IncludeDeleted also lists machines destroyed within the retention
+	// window, in the "destroyed" state.
+	IncludeDeleted bool
+	// PageSize is the number of machines ListPage asks for per page. Zero
+	// leaves it to the server.
+	PageSize int
+}
+
+// ByProcessGroup returns a filter matching the machines of a process group.
+func ByProcessGroup(name string) ListFilter {
+	return ListFilter{Metadata: map[string]string{MetadataProcessGroup: name}}
+}
+
+func (f ListFilter) validate() error {
+	for key, value := range f.Metadata {
+		if key == "" {
+			return errors.New("metadata filter keys must not be empty")
+		}
+		if value == "" {
+			return fmt.Errorf("metadata filter %q has an empty value", key)
+		}
+	}
+	return nil
+}
+
+func (f ListFilter) values() url.Values {
+	query := url.Values{}
+	if f.State != "" {
+		query.Set("state", string(f.State))
+	}
+	if f.Region != "" {
+		query.Set("region", f.Region)
+	}
+	if f.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	for key, value := range f.Metadata {
+		query.Set("metadata."+key, value)
+	}
+	return query
+}
+
 type StopMachineInput struct {
-	ID      string        `json:"id"`
+	ID string `json:"id"`
+	// Signal is sent to the machine's processes to stop them, and Timeout is
+	// how long they get to exit before they are killed. Zero values leave
+	// the server defaults in place.
 	Signal  Signal        `json:"signal,omitempty"`
 	Timeout time.Duration `json:"timeout,omitempty"`
 	Filters *Filters      `json:"filters,omitempty"`
 }
 
+// RestartOptions are optional parameters for restarting a machine. Zero values
+// leave the server defaults in place.
+type RestartOptions struct {
+	Timeout   time.Duration
+	ForceStop bool
+	Signal    string
+}
+
+type ExecRequest struct {
+	// Cmd is a shell command line, used when Command is empty.
+	Cmd     string   `json:"cmd,omitempty"`
+	Command []string `json:"command,omitempty"`
+	// Timeout is in seconds.
+	Timeout int    `json:"timeout,omitempty"`
+	Stdin   string `json:"stdin,omitempty"`
+}
+
+type ExecResponse struct {
+	ExitCode   int    `json:"exit_code"`
+	ExitSignal int    `json:"exit_signal,omitempty"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+}
+
+type Process struct {
+	PID       int32  `json:"pid"`
+	Command   string `json:"command"`
+	Stime     int64  `json:"stime"`
+	RSS       uint64 `json:"rss"`
+	CPU       uint64 `json:"cpu"`
+	Directory string `json:"directory"`
+}
+
 type MachineIP struct {
 	Family   string
 	Kind     string
@@ -95,7 +291,13 @@ type RemoveMachineInput struct {
 	AppID string `json:"appId,omitempty"`
 	ID    string `json:"id"`
 
-	Kill bool `json:"kill"`
+	// Kill stops a running machine before destroying it. Force destroys it
+	// whatever its state, even if it can't be stopped cleanly.
+	Kill  bool `json:"kill"`
+	Force bool `json:"force,omitempty"`
+
+	// Nonce is the nonce of a lease held on the machine.
+	Nonce string `json:"-"`
 }
 
 type MachineRestartPolicy string
@@ -105,22 +307,22 @@ var MachineRestartPolicyOnFailure MachineRestartPolicy = "on-failure"
 var MachineRestartPolicyAlways MachineRestartPolicy = "always"
 
 type MachineRestart struct {
-	Policy MachineRestartPolicy `json:"policy"`
+	Policy MachineRestartPolicy `json:"policy,omitempty"`
 	// MaxRetries is only relevant with the on-failure policy.
 	MaxRetries int `json:"max_retries,omitempty"`
 }
 
 type MachineMount struct {
-	Encrypted bool   `json:"encrypted"`
+	Encrypted bool   `json:"encrypted,omitempty"`
 	Path      string `json:"path"`
-	SizeGb    int    `json:"size_gb"`
+	SizeGb    int    `json:"size_gb,omitempty"`
 	Volume    string `json:"volume"`
 }
 
 type MachineGuest struct {
-	CPUKind  string `json:"cpu_kind"`
-	CPUs     int    `json:"cpus"`
-	MemoryMB int    `json:"memory_mb"`
+	CPUKind  string `json:"cpu_kind,omitempty"`
+	CPUs     int    `json:"cpus,omitempty"`
+	MemoryMB int    `json:"memory_mb,omitempty"`
 
 	KernelArgs []string `json:"kernel_args,omitempty"`
 }
@@ -161,22 +363,59 @@ type MachineService struct {
 
 type MachineServiceConcurrency struct {
 	Type      string `json:"type" toml:"type,omitempty"`
-	HardLimit int    `json:"hard_limit" toml:"hard_limit,omitempty"`
-	SoftLimit int    `json:"soft_limit" toml:"soft_limit,omitempty"`
+	HardLimit int    `json:"hard_limit,omitempty" toml:"hard_limit,omitempty"`
+	SoftLimit int    `json:"soft_limit,omitempty" toml:"soft_limit,omitempty"`
 }
 
 type MachineConfig struct {
-	Env      map[string]string `json:"env"`
-	Init     MachineInit       `json:"init,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Init     MachineInit       `json:"init,omitzero"`
 	Image    string            `json:"image"`
-	Metadata map[string]string `json:"metadata"`
+	Metadata map[string]string `json:"metadata,omitempty"`
 	Mounts   []MachineMount    `json:"mounts,omitempty"`
-	Restart  MachineRestart    `json:"restart,omitempty"`
+	Restart  MachineRestart    `json:"restart,omitzero"`
 	Services []MachineService  `json:"services,omitempty"`
 	VMSize   string            `json:"size,omitempty"`
 	Guest    *MachineGuest     `json:"guest,omitempty"`
-	Metrics  *MachineMetrics   `json:"metrics"`
+	Metrics  *MachineMetrics   `json:"metrics,omitempty"`
 	Schedule string            `json:"schedule,omitempty"`
+
+	// RegistryAuth holds the credentials to pull Image with, for images in
+	// private registries.
+	RegistryAuth *RegistryAuth `json:"registry_auth,omitempty"`
+}
+
+type RegistryAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type Volume struct {
+	ID                string  `json:"id"`
+	Name              string  `json:"name"`
+	State             string  `json:"state"`
+	SizeGb            int     `json:"size_gb"`
+	Region            string  `json:"region"`
+	Zone              string  `json:"zone"`
+	Encrypted         bool    `json:"encrypted"`
+	AttachedMachineID *string `json:"attached_machine_id"`
+	CreatedAt         string  `json:"created_at"`
+}
+
+type CreateVolumeInput struct {
+	Name       string  `json:"name"`
+	SizeGb     int     `json:"size_gb"`
+	Region     string  `json:"region"`
+	Encrypted  bool    `json:"encrypted"`
+	SnapshotID *string `json:"snapshot_id,omitempty"`
+}
+
+type Snapshot struct {
+	ID        string `json:"id"`
+	Size      int    `json:"size"`
+	Digest    string `json:"digest"`
+	CreatedAt string `json:"created_at"`
+	Status    string `json:"status,omitempty"`
 }
 
 type MachineLease struct {
@@ -194,6 +433,12 @@ type MachineStartResponse struct {
 	PreviousState string `json:"previous_state"`
 }
 
+type MachineStopResponse struct {
+	Message       string `json:"message,omitempty"`
+	Status        string `json:"status,omitempty"`
+	PreviousState string `json:"previous_state,omitempty"`
+}
+
 type LaunchMachineInput struct {
 	AppID   string         `json:"appId,omitempty"`
 	ID      string         `json:"id,omitempty"`
@@ -201,13 +446,19 @@ type LaunchMachineInput struct {
 	OrgSlug string         `json:"organizationId,omitempty"`
 	Region  string         `json:"region,omitempty"`
 	Config  *MachineConfig `json:"config"`
+
+	// SkipLaunch creates the machine without starting it; it stays in the
+	// created state until started. SkipServiceRegistration keeps it out of
+	// service discovery and the proxy until it is updated without the flag.
+	SkipLaunch              bool `json:"skip_launch,omitempty"`
+	SkipServiceRegistration bool `json:"skip_service_registration,omitempty"`
 }
 
 type MachineInit struct {
-	Exec       []string `json:"exec"`
-	Entrypoint []string `json:"entrypoint"`
-	Cmd        []string `json:"cmd"`
-	Tty        bool     `json:"tty"`
+	Exec       []string `json:"exec,omitempty"`
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Cmd        []string `json:"cmd,omitempty"`
+	Tty        bool     `json:"tty,omitempty"`
 }
 
 type Signal struct {
@@ -0,0 +1,147 @@
+package flaps
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// assertJSONEqual compares two JSON documents, ignoring formatting and the
+// order of object keys.
+func assertJSONEqual(t *testing.T, got, want []byte) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Fatalf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMachineUnmarshal(t *testing.T) {
+	var m Machine
+	if err := json.Unmarshal(readFixture(t, "machine.json"), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.ID != "148ed599c14189" || m.State != "started" || m.Region != "iad" {
+		t.Errorf("got id %q, state %q, region %q", m.ID, m.State, m.Region)
+	}
+	if got := m.FullImageRef(); got != "registry.fly.io/young-water:deployment-01HCNJX9Y4Z5M3V1P0Q2R8S7T6" {
+		t.Errorf("FullImageRef() = %q", got)
+	}
+	if got := m.ImageVersion(); got != "12" {
+		t.Errorf("ImageVersion() = %q, want 12", got)
+	}
+	if got := m.ProcessGroup(); got != "app" {
+		t.Errorf("ProcessGroup() = %q, want app", got)
+	}
+	if !m.AllChecksPassing() {
+		t.Errorf("FailingChecks() = %v, want none", m.FailingChecks())
+	}
+	if len(m.Events) != 2 || m.Events[0].Type != "start" || m.Events[0].Timestamp != 1697209443072 {
+		t.Errorf("unexpected events %+v", m.Events)
+	}
+
+	config := m.Config
+	if config == nil {
+		t.Fatal("config not decoded")
+	}
+	if !reflect.DeepEqual(config.Init.Cmd, []string{"bundle", "exec", "puma"}) {
+		t.Errorf("init.cmd = %v", config.Init.Cmd)
+	}
+	if config.Guest == nil || !reflect.DeepEqual(*config.Guest, MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: 256}) {
+		t.Errorf("guest = %+v", config.Guest)
+	}
+	if config.Restart != (MachineRestart{Policy: MachineRestartPolicyOnFailure, MaxRetries: 10}) {
+		t.Errorf("restart = %+v", config.Restart)
+	}
+	if len(config.Services) != 1 {
+		t.Fatalf("got %d services, want 1", len(config.Services))
+	}
+	service := config.Services[0]
+	if service.InternalPort != 8080 || len(service.Ports) != 2 || !service.Ports[0].ForceHttps {
+		t.Errorf("service = %+v", service)
+	}
+	if service.Concurrency == nil || service.Concurrency.HardLimit != 25 || service.Concurrency.SoftLimit != 20 {
+		t.Errorf("concurrency = %+v", service.Concurrency)
+	}
+}
+
+func TestLaunchMachineInputRoundTrip(t *testing.T) {
+	fixture := readFixture(t, "launch_input.json")
+
+	var in LaunchMachineInput
+	if err := json.Unmarshal(fixture, &in); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, b, fixture)
+}
+
+func TestMachineConfigOmitsUnsetFields(t *testing.T) {
+	b, err := json.Marshal(MachineConfig{Image: "nginx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, b, []byte(`{"image":"nginx"}`))
+}
+
+func TestWaitInstanceID(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine Machine
+		want    string
+	}{
+		{"version only", Machine{Version: "v1"}, "v1"},
+		{"instance id only", Machine{InstanceID: "i1"}, "i1"},
+		{"version wins", Machine{Version: "v1", InstanceID: "i1"}, "v1"},
+		{"neither", Machine{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.machine.WaitInstanceID(); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLaunchMachineInputSkipFlags(t *testing.T) {
+	config := &MachineConfig{Image: "nginx"}
+	tests := []struct {
+		name  string
+		input LaunchMachineInput
+		want  string
+	}{
+		{"unset", LaunchMachineInput{Config: config}, `{"config":{"image":"nginx"}}`},
+		{"skip launch", LaunchMachineInput{Config: config, SkipLaunch: true}, `{"config":{"image":"nginx"},"skip_launch":true}`},
+		{"skip service registration", LaunchMachineInput{Config: config, SkipServiceRegistration: true}, `{"config":{"image":"nginx"},"skip_service_registration":true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, b, []byte(tt.want))
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package flaps
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+const modulePath = "github.com/mikefrey/flaps"
+
+// defaultUserAgent is "flaps-go/<version>", with the version of this module
+// the running binary was built with.
+var defaultUserAgent = sync.OnceValue(func() string {
+	version := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath && info.Main.Version != "" {
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath && dep.Version != "" {
+				version = dep.Version
+			}
+		}
+	}
+	return "flaps-go/" + version
+})
+
+func (f *Client) userAgent() string {
+	if f.userAgentSuffix == "" {
+		return defaultUserAgent()
+	}
+	return defaultUserAgent() + " " + f.userAgentSuffix
+}
@@ -0,0 +1,34 @@
+package flaps
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"default", nil, defaultUserAgent()},
+		{"with product", []Option{WithUserAgent("mytool/1.2")}, defaultUserAgent() + " mytool/1.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("User-Agent")
+			}, tt.opts...)
+
+			if err := client.Cordon(context.Background(), "m1"); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(got, "flaps-go/") || got != tt.want {
+				t.Fatalf("got User-Agent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package flaps
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Validate checks the input for mistakes Flaps would otherwise reject with an
+// opaque error.
+func (in LaunchMachineInput) Validate() error {
+	if in.Config == nil {
+		return errors.New("config is required")
+	}
+	return in.Config.Validate()
+}
+
+// Validate checks the config for missing or obviously wrong values.
+func (c *MachineConfig) Validate() error {
+	if c.Image == "" {
+		return errors.New("config.image is required")
+	}
+
+	if c.Guest != nil {
+		if c.Guest.CPUs < 0 {
+			return fmt.Errorf("config.guest.cpus must not be negative, got %d", c.Guest.CPUs)
+		}
+		if c.Guest.MemoryMB < 0 {
+			return fmt.Errorf("config.guest.memory_mb must not be negative, got %d", c.Guest.MemoryMB)
+		}
+	}
+
+	// exec replaces the image's entrypoint and cmd, which would otherwise be
+	// silently ignored.
+	if len(c.Init.Exec) > 0 && (len(c.Init.Entrypoint) > 0 || len(c.Init.Cmd) > 0) {
+		return errors.New("config.init.exec can't be combined with config.init.entrypoint or config.init.cmd")
+	}
+
+	for i, mount := range c.Mounts {
+		if mount.Volume == "" {
+			return fmt.Errorf("config.mounts[%d].volume is required", i)
+		}
+		if mount.Path == "" {
+			return fmt.Errorf("config.mounts[%d].path is required", i)
+		}
+	}
+
+	for i, service := range c.Services {
+		if service.InternalPort <= 0 || service.InternalPort > 65535 {
+			return fmt.Errorf("config.services[%d].internal_port must be between 1 and 65535, got %d", i, service.InternalPort)
+		}
+	}
+
+	return nil
+}
+
+type guestLimits struct {
+	cpus                     []int
+	minMBPerCPU, maxMBPerCPU int
+}
+
+// guestSizes are the sizes Fly offers, by CPU kind.
+var guestSizes = map[string]guestLimits{
+	"shared":      {cpus: []int{1, 2, 4, 6, 8}, minMBPerCPU: MEMORY_MB_PER_SHARED_CPU, maxMBPerCPU: 2048},
+	"dedicated":   {cpus: []int{1, 2, 4, 8, 16}, minMBPerCPU: MEMORY_MB_PER_CPU, maxMBPerCPU: 8192},
+	"performance": {cpus: []int{1, 2, 4, 8, 16}, minMBPerCPU: MEMORY_MB_PER_CPU, maxMBPerCPU: 8192},
+}
+
+// validateGuestSize checks that cpus and memoryMB are a size Fly offers: a
+// supported CPU count, with memory in 256 MB steps within the per-CPU range
+// of the CPU kind.
+func validateGuestSize(kind string, cpus, memoryMB int) error {
+	limits, ok := guestSizes[kind]
+	if !ok {
+		return fmt.Errorf("unknown cpu kind %q", kind)
+	}
+	if !slices.Contains(limits.cpus, cpus) {
+		return fmt.Errorf("%d %s cpus are not offered, pick one of %v", cpus, kind, limits.cpus)
+	}
+
+	minMB, maxMB := cpus*limits.minMBPerCPU, cpus*limits.maxMBPerCPU
+	if memoryMB < minMB || memoryMB > maxMB || memoryMB%256 != 0 {
+		return fmt.Errorf("%d %s cpus take between %d and %d MB of memory in 256 MB steps, got %d MB", cpus, kind, minMB, maxMB, memoryMB)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+func (f *Client) CreateVolume(ctx context.Context, input CreateVolumeInput) (*Volume, error) {
+	out := new(Volume)
+
+	if err := f.sendAppRequest(ctx, "CreateVolume", http.MethodPost, f.volumesPath(""), input, out, nil); err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	return out, nil
+}
+
+func (f *Client) ListVolumes(ctx context.Context) ([]*Volume, error) {
+	out := make([]*Volume, 0)
+
+	if err := f.sendAppRequest(ctx, "ListVolumes", http.MethodGet, f.volumesPath(""), nil, &out, nil); err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	return out, nil
+}
+
+func (f *Client) GetVolume(ctx context.Context, volumeID string) (*Volume, error) {
+	endpoint := f.volumesPath(fmt.Sprintf("/%s", volumeID))
+
+	out := new(Volume)
+
+	if err := f.sendAppRequest(ctx, "GetVolume", http.MethodGet, endpoint, nil, out, nil); err != nil {
+		return nil, fmt.Errorf("failed to get volume %s: %w", volumeID, err)
+	}
+	return out, nil
+}
+
+func (f *Client) DeleteVolume(ctx context.Context, volumeID string) (*Volume, error) {
+	endpoint := f.volumesPath(fmt.Sprintf("/%s", volumeID))
+
+	out := new(Volume)
+
+	if err := f.sendAppRequest(ctx, "DeleteVolume", http.MethodDelete, endpoint, nil, out, nil); err != nil {
+		return nil, fmt.Errorf("failed to delete volume %s: %w", volumeID, err)
+	}
+	return out, nil
+}
+
+// ExtendVolume grows the volume to sizeGb and returns it with its new size.
+func (f *Client) ExtendVolume(ctx context.Context, volumeID string, sizeGb int) (*Volume, error) {
+	if sizeGb <= 0 {
+		return nil, fmt.Errorf("failed to extend volume %s: size must be positive, got %d", volumeID, sizeGb)
+	}
+
+	endpoint := f.volumesPath(fmt.Sprintf("/%s/extend", volumeID))
+
+	in := map[string]interface{}{
+		"size_gb": sizeGb,
+	}
+
+	out := struct {
+		Volume *Volume `json:"volume"`
+	}{}
+
+	if err := f.sendAppRequest(ctx, "ExtendVolume", http.MethodPut, endpoint, in, &out, nil); err != nil {
+		return nil, fmt.Errorf("failed to extend volume %s: %w", volumeID, err)
+	}
+	if out.Volume == nil {
+		return nil, fmt.Errorf("failed to extend volume %s: the response has no volume", volumeID)
+	}
+	return out.Volume, nil
+}
+
+// ListVolumeSnapshots returns the volume's snapshots, which is an empty slice
+// when it has none yet.
+func (f *Client) ListVolumeSnapshots(ctx context.Context, volumeID string) ([]*Snapshot, error) {
+	endpoint := f.volumesPath(fmt.Sprintf("/%s/snapshots", volumeID))
+
+	out := make([]*Snapshot, 0)
+
+	if err := f.sendAppRequest(ctx, "ListVolumeSnapshots", http.MethodGet, endpoint, nil, &out, nil); err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of volume %s: %w", volumeID, err)
+	}
+	if out == nil {
+		out = make([]*Snapshot, 0)
+	}
+	return out, nil
+}
+
+func (f *Client) CreateVolumeSnapshot(ctx context.Context, volumeID string) error {
+	endpoint := f.volumesPath(fmt.Sprintf("/%s/snapshots", volumeID))
+
+	if err := f.sendAppRequest(ctx, "CreateVolumeSnapshot", http.MethodPost, endpoint, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to snapshot volume %s: %w", volumeID, err)
+	}
+	return nil
+}
+
+func (f *Client) volumesPath(path string) string {
+	return fmt.Sprintf("/apps/%s/volumes%s", f.appName, path)
+}
@@ -0,0 +1,58 @@
+package flaps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestExtendVolume(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1/apps/test-app/volumes/vol_1/extend"; r.Method != http.MethodPut || r.URL.Path != want {
+			t.Errorf("got request %s %s, want PUT %s", r.Method, r.URL.Path, want)
+		}
+		var in struct {
+			SizeGb int `json:"size_gb"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.SizeGb != 20 {
+			t.Errorf("got size_gb %d (%v), want 20", in.SizeGb, err)
+		}
+		w.Write([]byte(`{"volume":{"id":"vol_1","size_gb":20},"needs_restart":true}`))
+	})
+
+	volume, err := client.ExtendVolume(context.Background(), "vol_1", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if volume.ID != "vol_1" || volume.SizeGb != 20 {
+		t.Fatalf("got volume %+v, want vol_1 at 20GB", volume)
+	}
+}
+
+func TestExtendVolumeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		sizeGb int
+		body   string
+	}{
+		{"zero size", 0, ""},
+		{"negative size", -1, ""},
+		{"no volume", 20, `{"needs_restart":false}`},
+		{"empty body", 20, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.sizeGb <= 0 {
+					t.Errorf("unexpected request for size %d", tt.sizeGb)
+				}
+				w.Write([]byte(tt.body))
+			})
+
+			if volume, err := client.ExtendVolume(context.Background(), "vol_1", tt.sizeGb); err == nil {
+				t.Fatalf("got volume %+v, want an error", volume)
+			}
+		})
+	}
+}
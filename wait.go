@@ -0,0 +1,140 @@
+package flaps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 60 * time.Second
+)
+
+// Wait blocks until machine reaches state, which defaults to "started",
+// giving up after timeout, or ctx's deadline if sooner. A zero timeout leaves
+// the bound to ctx. The server holds each wait request for at most 60s, so
+// Wait issues another whenever one times out server-side. Once ctx is done
+// or timeout passes, the request in flight is aborted, and the error returned
+// wraps context.Canceled or context.DeadlineExceeded.
+func (f *Client) Wait(ctx context.Context, machine *Machine, state MachineState, timeout time.Duration) (err error) {
+	if err := f.checkState(state); err != nil {
+		return fmt.Errorf("failed to wait for VM %s: %w", machine.ID, err)
+	}
+	if state == "" {
+		state = StateStarted
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for {
+		err := f.waitOnce(ctx, "Wait", machine, state, timeout)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to wait for VM %s in %s state: %w", machine.ID, state, ctx.Err())
+		}
+		if StatusCode(err) != http.StatusRequestTimeout {
+			return fmt.Errorf("failed to wait for VM %s in %s state: %w", machine.ID, state, err)
+		}
+	}
+}
+
+// terminalStates are states a machine doesn't leave on its own.
+var terminalStates = map[MachineState]bool{
+	StateDestroyed: true,
+	StateFailed:    true,
+}
+
+// WaitForState is like Wait, but returns the last state observed alongside
+// any error. It also gives up early when the machine reaches a terminal state
+// other than target.
+func (f *Client) WaitForState(ctx context.Context, machineID string, target MachineState, timeout time.Duration) (MachineState, error) {
+	if err := f.checkState(target); err != nil {
+		return "", fmt.Errorf("failed to wait for VM %s: %w", machineID, err)
+	}
+	if target == "" {
+		target = StateStarted
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	machine := &Machine{ID: machineID}
+
+	for {
+		err := f.waitOnce(ctx, "WaitForState", machine, target, 0)
+		if err == nil {
+			return target, nil
+		}
+
+		state := f.observeState(ctx, machineID)
+
+		if ctx.Err() != nil {
+			return state, fmt.Errorf("failed to wait for VM %s in %s state: %w", machineID, target, ctx.Err())
+		}
+		if StatusCode(err) != http.StatusRequestTimeout {
+			return state, fmt.Errorf("failed to wait for VM %s in %s state: %w", machineID, target, err)
+		}
+		if terminalStates[state] && state != target {
+			return state, fmt.Errorf("VM %s reached %s state while waiting for %s", machineID, state, target)
+		}
+	}
+}
+
+// observeState returns the machine's current state, or "" if it can't be
+// fetched. When ctx is already done, the lookup gets a short budget of its own.
+func (f *Client) observeState(ctx context.Context, machineID string) MachineState {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+	}
+
+	machine, err := f.Get(ctx, machineID)
+	if err != nil {
+		return ""
+	}
+	return MachineState(machine.State)
+}
+
+// waitOnce issues a single wait request, bounded by the lesser of timeout and
+// ctx's deadline.
+func (f *Client) waitOnce(ctx context.Context, operation string, machine *Machine, state MachineState, timeout time.Duration) error {
+	version := machine.WaitInstanceID()
+
+	switch {
+	case timeout <= 0:
+		timeout = defaultWaitTimeout
+	case timeout > maxWaitTimeout:
+		timeout = maxWaitTimeout
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+
+	query := url.Values{}
+	if version != "" {
+		query.Set("instance_id", version)
+	}
+	query.Set("timeout", strconv.Itoa(int(timeout/time.Second)))
+	query.Set("state", string(state))
+
+	waitEndpoint := fmt.Sprintf("/%s/wait?%s", machine.ID, query.Encode())
+
+	return f.sendRequest(ctx, operation, http.MethodGet, waitEndpoint, nil, nil, nil)
+}
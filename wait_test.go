@@ -0,0 +1,95 @@
+package flaps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitRetriesServerTimeouts(t *testing.T) {
+	var requests atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps/test-app/machines/m1/wait" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusRequestTimeout)
+			w.Write([]byte(`{"error":"deadline_exceeded: machine did not reach started state"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	if err := client.Wait(context.Background(), &Machine{ID: "m1"}, StateStarted, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+}
+
+func TestWaitTimeoutBoundsWholeWait(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusRequestTimeout)
+	})
+
+	start := time.Now()
+	err := client.Wait(context.Background(), &Machine{ID: "m1"}, StateStarted, 300*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Wait returned after %s", elapsed)
+	}
+}
+
+func TestWaitCancel(t *testing.T) {
+	aborted := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Hold the request like a server-side wait until the client gives
+		// up on it.
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(10 * time.Second):
+			w.Write([]byte(`{"ok":true}`))
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := client.Wait(ctx, &Machine{ID: "m1"}, StateStarted, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Wait returned %s after cancellation", elapsed)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the request in flight wasn't aborted")
+	}
+}
+
+func TestWaitSendsWaitInstanceID(t *testing.T) {
+	var got string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("instance_id")
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	machine := &Machine{ID: "m1", Version: "v1", InstanceID: "i1"}
+	if err := client.Wait(context.Background(), machine, StateStarted, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got != machine.WaitInstanceID() {
+		t.Fatalf("got instance_id %q, want %q", got, machine.WaitInstanceID())
+	}
+}